@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+)
+
+// runAPIKeyCommand manages API keys from the command line:
+//
+//	apikey create -name NAME -scope admin|service|read-only
+//	apikey rotate -id ID
+//	apikey revoke -id ID
+//	apikey list
+func runAPIKeyCommand(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apikey create|rotate|revoke|list")
+	}
+	cmd := flag.NewFlagSet("apikey "+args[0], flag.ContinueOnError)
+	name := cmd.String("name", "", "API key name")
+	scope := cmd.String("scope", entity.ScopeReadOnly, "API key scope: admin, service or read-only")
+	id := cmd.Int("id", 0, "API key id")
+	err := cmd.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "create":
+		key, secret, err := controller.CreateAPIKey(db, *name, *scope)
+		if err != nil {
+			return err
+		}
+		auditAPIKey(db, entity.AuditCreateAPIKey, key.ID, nil)
+		printAPIKey(key)
+		fmt.Printf("key: %s\n", secret)
+	case "rotate":
+		before, _ := controller.AuditSnapshot(db, controller.AuditTarget("api_key", *id))
+		key, secret, err := controller.RotateAPIKey(db, *id)
+		if err != nil {
+			return err
+		}
+		auditAPIKey(db, entity.AuditRotateAPIKey, *id, before)
+		auditAPIKey(db, entity.AuditCreateAPIKey, key.ID, nil)
+		printAPIKey(key)
+		fmt.Printf("key: %s\n", secret)
+	case "revoke":
+		before, _ := controller.AuditSnapshot(db, controller.AuditTarget("api_key", *id))
+		err = controller.RevokeAPIKey(db, *id)
+		if err != nil {
+			return err
+		}
+		auditAPIKey(db, entity.AuditRevokeAPIKey, *id, before)
+		fmt.Printf("API key %d revoked\n", *id)
+	case "list":
+		keys, err := controller.ListAPIKeys(db)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			printAPIKey(k)
+		}
+	default:
+		return fmt.Errorf("unknown apikey command %q", args[0])
+	}
+	return nil
+}
+
+// auditAPIKey writes the audit record of a key change made from the command line.
+func auditAPIKey(db *sql.DB, action string, keyID int, before json.RawMessage) {
+	record := entity.AuditRecord{Actor: "cli", Action: action, Target: controller.AuditTarget("api_key", keyID), Before: before}
+	if u, err := user.Current(); err == nil {
+		record.Actor = "cli:" + u.Username
+	}
+	var err error
+	record.After, err = controller.AuditSnapshot(db, record.Target)
+	if err == nil {
+		err = controller.Audit(db, record)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit:", err)
+	}
+}
+
+func printAPIKey(k entity.APIKey) {
+	status := "active"
+	if k.RevokedAt != nil {
+		status = "revoked " + k.RevokedAt.Format("2006-01-02 15:04:05")
+	}
+	fmt.Fprintf(os.Stdout, "%d\t%s\t%s\tcreated %s\t%s\n", k.ID, k.Name, k.Scope, k.CreatedAt.Format("2006-01-02 15:04:05"), status)
+}
@@ -0,0 +1,188 @@
+// Package auth verifies JSON Web Tokens signed with HS256 or RS256 and HMAC-SHA256 signed requests.
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Claims registered claims of a token together with the role of its holder.
+type Claims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  interface{} `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+	Role      string      `json:"role"`
+}
+
+// Verifier checks token signatures with the configured keys. A nil key disables its algorithm.
+type Verifier struct {
+	hmacKey   []byte
+	publicKey *rsa.PublicKey
+	issuer    string
+	audience  string
+	// Now returns the current time, tests may replace it
+	Now func() time.Time
+}
+
+// NewVerifier returns verifier for HS256 tokens signed with hmacKey and RS256 tokens signed
+// by the private key of publicKey. Empty issuer and audience are not checked.
+func NewVerifier(hmacKey []byte, publicKey *rsa.PublicKey, issuer string, audience string) *Verifier {
+	return &Verifier{hmacKey: hmacKey, publicKey: publicKey, issuer: issuer, audience: audience, Now: time.Now}
+}
+
+// LoadVerifier reads the HS256 secret and the PEM encoded RS256 public key from files,
+// an empty file name disables the algorithm. It returns nil when both are disabled.
+func LoadVerifier(secretFile string, publicKeyFile string, issuer string, audience string) (*Verifier, error) {
+	var secret []byte
+	var publicKey *rsa.PublicKey
+	if secretFile != "" {
+		b, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = []byte(strings.TrimSpace(string(b)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("HS256 secret in %s must be at least 32 bytes", secretFile)
+		}
+	}
+	if publicKeyFile != "" {
+		b, err := ioutil.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err = ParseRSAPublicKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", publicKeyFile, err)
+		}
+	}
+	if secret == nil && publicKey == nil {
+		return nil, nil
+	}
+	return NewVerifier(secret, publicKey, issuer, audience), nil
+}
+
+// ParseRSAPublicKey parses PEM encoded PKIX or PKCS #1 RSA public key or certificate.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("not an RSA public key")
+}
+
+// Verify checks the signature and the time, issuer and audience claims of the token and returns its claims.
+// Tokens must carry a subject and an expiry time.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return claims, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("malformed token signature")
+	}
+	switch {
+	case header.Alg == "HS256" && v.hmacKey != nil:
+		mac := hmac.New(sha256.New, v.hmacKey)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return claims, fmt.Errorf("invalid token signature")
+		}
+	case header.Alg == "RS256" && v.publicKey != nil:
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, sum[:], signature) != nil {
+			return claims, fmt.Errorf("invalid token signature")
+		}
+	default:
+		return claims, fmt.Errorf("token algorithm %q is not accepted", header.Alg)
+	}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return claims, err
+	}
+	now := v.Now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return claims, fmt.Errorf("token is expired")
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return claims, fmt.Errorf("token is not valid yet")
+	}
+	if claims.Subject == "" {
+		return claims, fmt.Errorf("token has no subject")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return claims, fmt.Errorf("token issuer %q is not accepted", claims.Issuer)
+	}
+	if v.audience != "" && !hasAudience(claims.Audience, v.audience) {
+		return claims, fmt.Errorf("token is not meant for this audience")
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or a list of strings, contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
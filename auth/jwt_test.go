@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func signToken(t *testing.T, alg string, claims map[string]interface{}, sign func([]byte) []byte) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	assert.NoError(t, err, "marshal header failed")
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err, "marshal claims failed")
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(data []byte) []byte {
+	mac := hmac.New(sha256.New, testSecret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v := NewVerifier(testSecret, nil, "appREST", "")
+	exp := time.Now().Add(time.Hour).Unix()
+
+	claims, err := v.Verify(signToken(t, "HS256", map[string]interface{}{"sub": "7", "exp": exp, "iss": "appREST"}, hs256))
+	assert.NoError(t, err, "func Verify failed")
+	assert.Equal(t, "7", claims.Subject, "subject mismatch")
+
+	_, err = v.Verify(signToken(t, "HS256", map[string]interface{}{"sub": "7", "exp": time.Now().Add(-time.Minute).Unix(), "iss": "appREST"}, hs256))
+	assert.Error(t, err, "expired token should be rejected")
+	_, err = v.Verify(signToken(t, "HS256", map[string]interface{}{"sub": "7", "exp": exp, "iss": "other"}, hs256))
+	assert.Error(t, err, "token of another issuer should be rejected")
+	_, err = v.Verify(signToken(t, "none", map[string]interface{}{"sub": "7", "exp": exp, "iss": "appREST"},
+		func([]byte) []byte { return nil }))
+	assert.Error(t, err, "unsigned token should be rejected")
+	token := signToken(t, "HS256", map[string]interface{}{"sub": "7", "exp": exp, "iss": "appREST"}, hs256)
+	_, err = v.Verify(token[:len(token)-2] + "xx")
+	assert.Error(t, err, "token with a broken signature should be rejected")
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err, "generate key failed")
+	v := NewVerifier(nil, &key.PublicKey, "", "players")
+	rs256 := func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		assert.NoError(t, err, "sign failed")
+		return sig
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	claims, err := v.Verify(signToken(t, "RS256", map[string]interface{}{"sub": "1", "exp": exp, "aud": []string{"players"}, "role": "admin"}, rs256))
+	assert.NoError(t, err, "func Verify failed")
+	assert.Equal(t, "admin", claims.Role, "role mismatch")
+	_, err = v.Verify(signToken(t, "HS256", map[string]interface{}{"sub": "1", "exp": exp, "aud": "players"}, hs256))
+	assert.Error(t, err, "HS256 should be rejected without a secret")
+	_, err = v.Verify(signToken(t, "RS256", map[string]interface{}{"sub": "1", "exp": exp, "aud": "games"}, rs256))
+	assert.Error(t, err, "token for another audience should be rejected")
+}
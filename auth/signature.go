@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a signed request
+const (
+	IntegrationHeader = "X-Integration"
+	TimestampHeader   = "X-Timestamp"
+	NonceHeader       = "X-Nonce"
+	SignatureHeader   = "X-Signature"
+)
+
+// DefaultSignatureSkew how far the request timestamp may be from the server clock
+const DefaultSignatureSkew = 5 * time.Minute
+
+// MaxBodySize largest request body read for a signature check
+const MaxBodySize = 1 << 20
+
+// SignedRequest verified headers of a signed request.
+type SignedRequest struct {
+	Integration string
+	Nonce       string
+	// Expires time after which the timestamp is rejected and the nonce needn't be kept
+	Expires time.Time
+}
+
+// SignatureVerifier checks HMAC-SHA256 signatures of requests with the secrets of the integrations.
+type SignatureVerifier struct {
+	secrets map[string][]byte
+	skew    time.Duration
+	// Now returns the current time, tests may replace it
+	Now func() time.Time
+}
+
+// NewSignatureVerifier returns verifier for the integration secrets. Requests with a timestamp
+// further than skew from now are rejected, zero skew means DefaultSignatureSkew.
+func NewSignatureVerifier(secrets map[string][]byte, skew time.Duration) *SignatureVerifier {
+	if skew <= 0 {
+		skew = DefaultSignatureSkew
+	}
+	return &SignatureVerifier{secrets: secrets, skew: skew, Now: time.Now}
+}
+
+// LoadSignatureVerifier reads the integration secrets from a file with one "integration secret" pair
+// per line, empty lines and lines starting with # are skipped. It returns nil for an empty file name.
+func LoadSignatureVerifier(secretsFile string, skew time.Duration) (*SignatureVerifier, error) {
+	if secretsFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(secretsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	secrets := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected integration and secret", secretsFile, n)
+		}
+		if len(fields[1]) < 32 {
+			return nil, fmt.Errorf("%s:%d: secret of %s must be at least 32 bytes", secretsFile, n, fields[0])
+		}
+		secrets[fields[0]] = []byte(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewSignatureVerifier(secrets, skew), nil
+}
+
+// canonicalRequest returns the signed string: method, path, sorted query, timestamp, nonce
+// and the hex encoded SHA-256 hash of the body, separated by new lines.
+func canonicalRequest(r *http.Request, timestamp string, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n"))
+}
+
+// SignRequest sets the signature headers of the request, clients of the integration use the same scheme.
+func SignRequest(r *http.Request, integration string, secret []byte, timestamp time.Time, nonce string, body []byte) {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(canonicalRequest(r, ts, nonce, body))
+	r.Header.Set(IntegrationHeader, integration)
+	r.Header.Set(TimestampHeader, ts)
+	r.Header.Set(NonceHeader, nonce)
+	r.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// IsSigned reports whether the request carries a signature.
+func IsSigned(r *http.Request) bool {
+	return r.Header.Get(SignatureHeader) != ""
+}
+
+// Verify checks the signature and the timestamp of the request with the body already read from it.
+// The caller must reject the nonce if it was used before the returned expiry.
+func (v *SignatureVerifier) Verify(r *http.Request, body []byte) (SignedRequest, error) {
+	integration := r.Header.Get(IntegrationHeader)
+	secret, ok := v.secrets[integration]
+	if !ok {
+		return SignedRequest{}, fmt.Errorf("unknown integration %q", integration)
+	}
+	ts := r.Header.Get(TimestampHeader)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return SignedRequest{}, fmt.Errorf("invalid signature timestamp %q", ts)
+	}
+	timestamp := time.Unix(unix, 0)
+	now := v.Now()
+	if timestamp.Before(now.Add(-v.skew)) || timestamp.After(now.Add(v.skew)) {
+		return SignedRequest{}, fmt.Errorf("signature timestamp %s is too far from now", timestamp.UTC().Format(time.RFC3339))
+	}
+	nonce := r.Header.Get(NonceHeader)
+	if len(nonce) < 16 || len(nonce) > 64 {
+		return SignedRequest{}, fmt.Errorf("signature nonce must have 16 to 64 characters")
+	}
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return SignedRequest{}, fmt.Errorf("invalid signature encoding")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(canonicalRequest(r, ts, nonce, body))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return SignedRequest{}, fmt.Errorf("invalid signature of integration %q", integration)
+	}
+	return SignedRequest{Integration: integration, Nonce: nonce, Expires: timestamp.Add(v.skew)}, nil
+}
+
+// ReadBody reads the request body and puts it back, so the handler can read it again.
+// Bodies larger than MaxBodySize are refused.
+func ReadBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
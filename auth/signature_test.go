@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	v := NewSignatureVerifier(map[string][]byte{"game-1": testSecret}, time.Minute)
+	v.Now = func() time.Time { return now }
+	body := `{"results":[{"playerId":1,"place":1}]}`
+	b := []byte(body)
+
+	r := httptest.NewRequest("POST", "/finishTournament?tournamentId=7", strings.NewReader(body))
+	SignRequest(r, "game-1", testSecret, now, "0123456789abcdef", b)
+	req, err := v.Verify(r, b)
+	assert.NoError(t, err, "signed request should be accepted")
+	assert.Equal(t, "game-1", req.Integration, "wrong integration")
+	assert.True(t, now.Add(time.Minute).Equal(req.Expires), "wrong nonce expiry")
+
+	_, err = v.Verify(r, []byte(`{"results":[{"playerId":2,"place":1}]}`))
+	assert.Error(t, err, "changed body should be rejected")
+
+	r.URL.RawQuery = "tournamentId=8"
+	_, err = v.Verify(r, b)
+	assert.Error(t, err, "changed query should be rejected")
+
+	r = httptest.NewRequest("POST", "/finishTournament?tournamentId=7", nil)
+	SignRequest(r, "game-1", testSecret, now.Add(-2*time.Minute), "0123456789abcdef", b)
+	_, err = v.Verify(r, b)
+	assert.Error(t, err, "stale timestamp should be rejected")
+
+	SignRequest(r, "game-2", testSecret, now, "0123456789abcdef", b)
+	_, err = v.Verify(r, b)
+	assert.Error(t, err, "unknown integration should be rejected")
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval how often the certificate files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+// CertReloader serves the certificate of the key pair files and reloads it when the files change,
+// so rotated certificates are used without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+	// Now returns the current time, tests may replace it
+	Now func() time.Time
+}
+
+// NewCertReloader loads the key pair, it fails when the files can't be loaded.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile, Now: time.Now}
+	err := c.reload()
+	return c, err
+}
+
+// modified returns the newest modification time of the certificate and key files.
+func (c *CertReloader) modified() (time.Time, error) {
+	var newest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return newest, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+func (c *CertReloader) reload() error {
+	modTime, err := c.modified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns the current certificate, it is used as tls.Config.GetCertificate.
+// A certificate which fails to load keeps the previous one in use, the rotation may be half done.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Now()
+	if now.Sub(c.checked) >= certCheckInterval {
+		c.checked = now
+		modTime, err := c.modified()
+		if err == nil && !modTime.Equal(c.modTime) {
+			c.reload()
+		}
+	}
+	return c.cert, nil
+}
+
+// LoadCertPool reads PEM encoded CA certificates from the file.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// NewTLSConfig returns server TLS config with the reloaded certificate. With a client CA file the client
+// certificates are verified against it, they are needed only when requireClientCert is set.
+func NewTLSConfig(certFile string, keyFile string, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile != "" {
+		config.ClientCAs, err = LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
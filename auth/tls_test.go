@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCert(t *testing.T, dir string, cn string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err, "generate key failed")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err, "create certificate failed")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err, "marshal key failed")
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	assert.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func commonName(t *testing.T, c *CertReloader) string {
+	cert, err := c.GetCertificate(nil)
+	assert.NoError(t, err, "get certificate failed")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err, "parse certificate failed")
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err, "create temp dir failed")
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeTestCert(t, dir, "first", start)
+	c, err := NewCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	assert.NoError(t, err, "load certificate failed")
+	now := start
+	c.Now = func() time.Time { return now }
+	assert.Equal(t, "first", commonName(t, c), "wrong certificate")
+
+	writeTestCert(t, dir, "second", start.Add(time.Hour))
+	assert.Equal(t, "first", commonName(t, c), "files should not be checked before the interval")
+	now = now.Add(certCheckInterval)
+	assert.Equal(t, "second", commonName(t, c), "rotated certificate should be reloaded")
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("broken"), 0600))
+	now = now.Add(certCheckInterval)
+	assert.Equal(t, "second", commonName(t, c), "broken certificate should keep the previous one")
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// SuspendedEntryPolicy what happens to open tournament entries of a suspended player.
+// Entries of closed accounts are always refunded.
+var SuspendedEntryPolicy = entity.EntryPolicyKeep
+
+// accountStatus returns the status of the player account at the time. A self-exclusion
+// with an end is over once the end has passed.
+func accountStatus(player entity.Player, now time.Time) string {
+	if player.Status == entity.AccountSelfExcluded && player.ExcludedUntil != nil && !now.Before(*player.ExcludedUntil) {
+		return entity.AccountActive
+	}
+	if player.Status == "" {
+		return entity.AccountActive
+	}
+	return player.Status
+}
+
+// checkAccount refuses money operations of players whose account is not active.
+func checkAccount(player entity.Player, now time.Time) error {
+	switch accountStatus(player, now) {
+	case entity.AccountActive:
+		return nil
+	case entity.AccountSelfExcluded:
+		if player.ExcludedUntil != nil {
+			return limitError(fmt.Sprintf("user %d is self-excluded until %s", player.ID, player.ExcludedUntil.UTC().Format(time.RFC3339)))
+		}
+		return limitError(fmt.Sprintf("user %d is self-excluded", player.ID))
+	default:
+		return limitError(fmt.Sprintf("user %d account is %s", player.ID, player.Status))
+	}
+}
+
+// errEntriesChanged the player entered a tournament or a waitlist while the tournaments were being locked
+var errEntriesChanged = errors.New("entries changed")
+
+// SetAccountStatus changes the account status of the player. Closed accounts can't be reopened and
+// a self-exclusion can't be lifted before its end. Closing the account refunds the entries of tournaments
+// which have not started yet and drops the player from waitlists, suspending does so when
+// SuspendedEntryPolicy is refund.
+func SetAccountStatus(db *sql.DB, playerID int, status string, reason string, actor string) ([]byte, error) {
+	switch status {
+	case entity.AccountActive, entity.AccountSuspended, entity.AccountSelfExcluded, entity.AccountClosed:
+	default:
+		return nil, fmt.Errorf("unknown account status %q", status)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("reason of the status change must not be empty")
+	}
+	withdraw := status == entity.AccountClosed || (status == entity.AccountSuspended && SuspendedEntryPolicy == entity.EntryPolicyRefund)
+	change := entity.AccountStatusChange{PlayerID: playerID, Status: status, Reason: reason, Actor: actor}
+	refunded, err := setAccountStatus(db, change, withdraw)
+	for attempt := 1; err == errEntriesChanged && attempt < 3; attempt++ {
+		refunded, err = setAccountStatus(db, change, withdraw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := accountInfo(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	res.RefundedEntries = refunded
+	return json.Marshal(res)
+}
+
+// setAccountStatus stores the status change and withdraws the entries when asked to. Tournaments are
+// locked before the player as everywhere else, so the tournaments to withdraw from are read before the
+// player is locked. It returns errEntriesChanged when the player entered another one meanwhile.
+func setAccountStatus(db *sql.DB, change entity.AccountStatusChange, withdraw bool) ([]int, error) {
+	playerID := change.PlayerID
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var tournaments map[int]entity.Tournament
+	if withdraw {
+		tournaments, err = lockPlayerTournaments(tx, playerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player.Status == entity.AccountClosed {
+		return nil, fmt.Errorf("user %d account is closed", playerID)
+	}
+	if change.Status == entity.AccountActive && accountStatus(player, time.Now()) == entity.AccountSelfExcluded {
+		return nil, fmt.Errorf("user %d is self-excluded, the account can't be activated before the exclusion ends", playerID)
+	}
+	err = database.SetPlayerStatus(tx, change)
+	if err != nil {
+		return nil, err
+	}
+	var refunded []int
+	if withdraw {
+		refunded, err = withdrawEntries(tx, playerID, tournaments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refunded, tx.Commit()
+}
+
+// lockPlayerTournaments locks the tournaments the player has open entries in or is waitlisted for,
+// in the order of their ids, and returns them by id.
+func lockPlayerTournaments(tx *sql.Tx, playerID int) (map[int]entity.Tournament, error) {
+	ids, err := database.SelectPlayerWaitlists(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := database.SelectPlayerOpenEntries(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		ids = append(ids, e.TournamentID)
+	}
+	sort.Ints(ids)
+	tournaments := make(map[int]entity.Tournament, len(ids))
+	for _, id := range ids {
+		if _, ok := tournaments[id]; ok {
+			continue
+		}
+		tournaments[id], err = database.SelectTournamentForUpdate(tx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tournaments, nil
+}
+
+// withdrawEntries removes the player from the waitlists and from the tournaments which have not started yet
+// and refunds the entries. Team entries are kept, the team has paid them together. The tournaments must
+// have been locked by lockPlayerTournaments. It returns the ids of the refunded tournaments.
+func withdrawEntries(tx *sql.Tx, playerID int, tournaments map[int]entity.Tournament) ([]int, error) {
+	waitlists, err := database.SelectPlayerWaitlists(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range waitlists {
+		if _, ok := tournaments[id]; !ok {
+			return nil, errEntriesChanged
+		}
+		_, err = database.DeleteWaitlistEntry(tx, id, playerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	entries, err := database.SelectPlayerOpenEntries(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	refunded := make([]int, 0, len(entries))
+	for _, e := range entries {
+		tournament, ok := tournaments[e.TournamentID]
+		if !ok {
+			return nil, errEntriesChanged
+		}
+		entry, err := database.SelectTournamentUser(tx, e.TournamentID, playerID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = removeEntry(tx, &tournament, entry)
+		if err != nil {
+			return nil, err
+		}
+		if tournament.Status == entity.TournamentIsAnnounced {
+			promoted, err := promoteWaitlist(tx, tournament)
+			if err != nil {
+				return nil, err
+			}
+			if promoted != 0 {
+				log.Printf("account: user %d took the place of user %d in tournament %d", promoted, playerID, tournament.ID)
+			}
+		}
+		refunded = append(refunded, tournament.ID)
+	}
+	return refunded, nil
+}
+
+func accountInfo(db *sql.DB, playerID int) (entity.AccountInfo, error) {
+	player, err := database.SelectPlayer(db, playerID)
+	if err != nil {
+		return entity.AccountInfo{}, err
+	}
+	history, err := database.SelectPlayerStatusHistory(db, playerID)
+	if err != nil {
+		return entity.AccountInfo{}, err
+	}
+	res := entity.AccountInfo{
+		PlayerID:  playerID,
+		Status:    accountStatus(player, time.Now()),
+		Reason:    player.StatusReason,
+		ChangedAt: player.StatusChangedAt,
+		History:   history,
+	}
+	if res.Status == entity.AccountSelfExcluded {
+		res.ExcludedUntil = player.ExcludedUntil
+	}
+	return res, nil
+}
+
+// GetAccount get the account status of the player with its history.
+func GetAccount(db *sql.DB, playerID int) ([]byte, error) {
+	res, err := accountInfo(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// apiKeyPrefix marks API keys so they are easy to recognize in configs and logs
+const apiKeyPrefix = "ak_"
+
+// hashAPIKey returns hex encoded SHA-256 hash of the key.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// CreateAPIKey creates a key with the scope. The key itself is returned only here, the database keeps its hash.
+func CreateAPIKey(db *sql.DB, name string, scope string) (entity.APIKey, string, error) {
+	if name == "" {
+		return entity.APIKey{}, "", fmt.Errorf("API key name must not be empty")
+	}
+	if role := KeyRole(scope); role == "" || role == entity.RolePlayer {
+		return entity.APIKey{}, "", fmt.Errorf("unknown API key scope %q", scope)
+	}
+	key, err := newAPIKey()
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	id, err := database.InsertAPIKey(db, entity.APIKey{Name: name, Hash: hashAPIKey(key), Scope: scope})
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	created, err := database.SelectAPIKey(db, id)
+	return created, key, err
+}
+
+// RotateAPIKey replaces the key by a new one with the same name and scope and revokes the old one.
+func RotateAPIKey(db *sql.DB, keyID int) (entity.APIKey, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	defer tx.Rollback()
+
+	old, err := database.SelectAPIKey(tx, keyID)
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	ok, err := database.RevokeAPIKey(tx, keyID)
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	if !ok {
+		return entity.APIKey{}, "", fmt.Errorf("API key %d is revoked", keyID)
+	}
+	key, err := newAPIKey()
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	id, err := database.InsertAPIKey(tx, entity.APIKey{Name: old.Name, Hash: hashAPIKey(key), Scope: old.Scope})
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	created, err := database.SelectAPIKey(tx, id)
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	return created, key, tx.Commit()
+}
+
+// RevokeAPIKey revokes the key, requests with it are rejected from now on.
+func RevokeAPIKey(db *sql.DB, keyID int) error {
+	ok, err := database.RevokeAPIKey(db, keyID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("API key %d does not exist or is revoked", keyID)
+	}
+	return nil
+}
+
+// ListAPIKeys returns all keys without the keys themselves.
+func ListAPIKeys(db *sql.DB) ([]entity.APIKey, error) {
+	return database.SelectAPIKeys(db)
+}
+
+// AuthenticateAPIKey returns the active key matching the presented key.
+func AuthenticateAPIKey(db *sql.DB, key string) (entity.APIKey, error) {
+	k, err := database.SelectActiveAPIKeyByHash(db, hashAPIKey(key))
+	if err == sql.ErrNoRows {
+		return k, fmt.Errorf("invalid API key")
+	}
+	return k, err
+}
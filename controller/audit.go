@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// Audit export formats
+const (
+	AuditFormatJSON = "json"
+	AuditFormatCSV  = "csv"
+)
+
+// auditHash returns the hash of the record chained to the hash of the previous record.
+func auditHash(record entity.AuditRecord) string {
+	h := sha256.New()
+	for _, field := range []string{
+		record.PrevHash,
+		record.Actor,
+		record.Action,
+		record.Target,
+		string(record.Before),
+		string(record.After),
+		record.SourceIP,
+		record.RequestID,
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// maxAuditActorLength longest actor the audit log keeps
+const maxAuditActorLength = 100
+
+// auditActor bounds the actor to the length the audit log keeps. A longer actor is cut
+// and ends in its hash, so different callers stay apart.
+func auditActor(actor string) string {
+	runes := []rune(actor)
+	if len(runes) <= maxAuditActorLength {
+		return actor
+	}
+	sum := sha256.Sum256([]byte(actor))
+	return string(runes[:maxAuditActorLength-1-sha256.Size*2]) + "#" + hex.EncodeToString(sum[:])
+}
+
+// AuditWriter holds the audit log lock from before an audited action until its record is written,
+// so no other audited action runs between the snapshots of the target.
+type AuditWriter struct {
+	tx *sql.Tx
+}
+
+// BeginAudit locks the audit log for the record of an action.
+func BeginAudit(db *sql.DB) (*AuditWriter, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	err = database.LockAuditLog(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &AuditWriter{tx: tx}, nil
+}
+
+// Write appends the record to the audit log and releases the lock.
+func (a *AuditWriter) Write(record entity.AuditRecord) error {
+	var err error
+	record.Actor = auditActor(record.Actor)
+	record.PrevHash, err = database.SelectLastAuditHash(a.tx)
+	if err != nil {
+		return err
+	}
+	// the database keeps microseconds, the hash must cover the stored time
+	record.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	record.Hash = auditHash(record)
+	_, err = database.InsertAuditRecord(a.tx, record)
+	if err != nil {
+		return err
+	}
+	return a.tx.Commit()
+}
+
+// Close releases the lock, it does nothing after the record has been written.
+func (a *AuditWriter) Close() {
+	a.tx.Rollback()
+}
+
+// Audit appends the record to the audit log. The writers are serialized so every record
+// is chained to the one written before it.
+func Audit(db *sql.DB, record entity.AuditRecord) error {
+	audit, err := BeginAudit(db)
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+	return audit.Write(record)
+}
+
+// AuditTarget returns the audit target name of the object.
+func AuditTarget(kind string, id int) string {
+	return kind + ":" + strconv.Itoa(id)
+}
+
+// AuditSnapshot returns the current state of the audit target as JSON, nil when it does not exist.
+func AuditSnapshot(db *sql.DB, target string) (json.RawMessage, error) {
+	parts := strings.SplitN(target, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid audit target %q", target)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit target %q", target)
+	}
+	var js []byte
+	switch parts[0] {
+	case "player":
+		js, err = GetUserBalance(db, id)
+	case "tournament":
+		js, err = GetTournament(db, id)
+	case "template":
+		js, err = GetTemplate(db, id)
+	case "account":
+		js, err = GetAccount(db, id)
+	case "kyc":
+		js, err = GetKYC(db, id)
+	case "api_key":
+		var key entity.APIKey
+		key, err = database.SelectAPIKey(db, id)
+		if err == nil {
+			js, err = json.Marshal(struct {
+				Name      string     `json:"name"`
+				Scope     string     `json:"scope"`
+				RevokedAt *time.Time `json:"revokedAt,omitempty"`
+			}{key.Name, key.Scope, key.RevokedAt})
+		}
+	default:
+		return nil, fmt.Errorf("unknown audit target %q", target)
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return js, err
+}
+
+// GetAuditLog get audit records matching the filter from database layer.
+func GetAuditLog(db *sql.DB, filter entity.AuditFilter) ([]byte, error) {
+	records, err := database.SelectAuditRecords(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.AuditRecords{Records: records})
+}
+
+// verifyAuditChain checks that every record follows the previous one and was not changed.
+// It returns the id of the first broken record or 0.
+func verifyAuditChain(records []entity.AuditRecord) int {
+	prev := ""
+	for _, r := range records {
+		if r.PrevHash != prev || auditHash(r) != r.Hash {
+			return r.ID
+		}
+		prev = r.Hash
+	}
+	return 0
+}
+
+// VerifyAuditLog recomputes the hash chain of the whole audit log.
+func VerifyAuditLog(db *sql.DB) ([]byte, error) {
+	records, err := database.SelectAuditRecords(db, entity.AuditFilter{})
+	if err != nil {
+		return nil, err
+	}
+	broken := verifyAuditChain(records)
+	return json.Marshal(entity.AuditVerification{Records: len(records), Valid: broken == 0, BrokenAt: broken})
+}
+
+// ExportAuditLog exports audit records matching the filter as JSON lines or CSV.
+// The hashes are exported too, so the chain can be checked outside the service.
+func ExportAuditLog(db *sql.DB, filter entity.AuditFilter, format string) ([]byte, error) {
+	records, err := database.SelectAuditRecords(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch format {
+	case AuditFormatJSON:
+		enc := json.NewEncoder(&buf)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return nil, err
+			}
+		}
+	case AuditFormatCSV:
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"id", "actor", "action", "target", "before", "after", "source_ip", "request_id", "created_at", "prev_hash", "hash"})
+		for _, r := range records {
+			w.Write([]string{strconv.Itoa(r.ID), r.Actor, r.Action, r.Target, string(r.Before), string(r.After),
+				r.SourceIP, r.RequestID, r.CreatedAt.UTC().Format(time.RFC3339Nano), r.PrevHash, r.Hash})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown audit export format %q", format)
+	}
+	return buf.Bytes(), nil
+}
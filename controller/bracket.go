@@ -0,0 +1,412 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// Elimination brackets are generated completely when the tournament is seeded. Every match knows which
+// match and slot its winner and loser move on to, so reporting a result only has to fill the next slots.
+// Empty slots are byes: a match whose both slots are filled but which has only one player is won
+// by that player without playing, a match without players produces no winner at all.
+
+// bracketSize returns the smallest power of two not less than n.
+func bracketSize(n int) int {
+	size := 1
+	for size < n {
+		size *= 2
+	}
+	return size
+}
+
+// seedOrder returns seed numbers in bracket position order, so that seeds 1 and 2 can only meet in the final.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		n := len(order)*2 + 1
+		for _, s := range order {
+			next = append(next, s, n-s)
+		}
+		order = next
+	}
+	return order
+}
+
+// buildBracket generates all matches of a single or double elimination bracket for the seeded players,
+// best seed first. The grand final of a double elimination bracket is a single match.
+func buildBracket(tournamentID int, seeds []int, double bool) []entity.Match {
+	size := bracketSize(len(seeds))
+	rounds := 0
+	for s := size; s > 1; s /= 2 {
+		rounds++
+	}
+	matches := make([]entity.Match, 0, size*2)
+	add := func(bracket string, round int, position int) int {
+		matches = append(matches, entity.Match{
+			TournamentID: tournamentID,
+			Number:       len(matches) + 1,
+			Bracket:      bracket,
+			Round:        round,
+			Position:     position,
+			Status:       entity.MatchPending,
+		})
+		return len(matches)
+	}
+
+	// winners bracket, wb[r][p] is the number of the match at round r+1, position p+1
+	wb := make([][]int, rounds)
+	for r := 0; r < rounds; r++ {
+		for p := 0; p < size>>(r+1); p++ {
+			wb[r] = append(wb[r], add(entity.BracketWinners, r+1, p+1))
+		}
+	}
+	order := seedOrder(size)
+	for p, number := range wb[0] {
+		m := &matches[number-1]
+		if seed := order[2*p]; seed <= len(seeds) {
+			m.Player1 = seeds[seed-1]
+		}
+		if seed := order[2*p+1]; seed <= len(seeds) {
+			m.Player2 = seeds[seed-1]
+		}
+		m.Filled = 2
+	}
+	for r := 0; r+1 < rounds; r++ {
+		for p, number := range wb[r] {
+			m := &matches[number-1]
+			m.WinnerTo, m.WinnerSlot = wb[r+1][p/2], p%2+1
+		}
+	}
+	if !double {
+		return matches
+	}
+
+	// losers bracket, odd rounds pair the survivors, even rounds bring in the losers of the next winners round
+	lb := make([][]int, 2*(rounds-1))
+	for j := range lb {
+		count := size >> (j/2 + 2)
+		for p := 0; p < count; p++ {
+			lb[j] = append(lb[j], add(entity.BracketLosers, j+1, p+1))
+		}
+	}
+	grandFinal := add(entity.BracketGrandFinal, 1, 1)
+	if len(lb) == 0 {
+		final := &matches[wb[0][0]-1]
+		final.WinnerTo, final.WinnerSlot = grandFinal, 1
+		final.LoserTo, final.LoserSlot = grandFinal, 2
+		return matches
+	}
+	for p, number := range wb[0] {
+		m := &matches[number-1]
+		m.LoserTo, m.LoserSlot = lb[0][p/2], p%2+1
+	}
+	for j := 0; j < len(lb); j++ {
+		for p, number := range lb[j] {
+			m := &matches[number-1]
+			switch {
+			case j == len(lb)-1:
+				m.WinnerTo, m.WinnerSlot = grandFinal, 2
+			case j%2 == 0:
+				m.WinnerTo, m.WinnerSlot = lb[j+1][p], 1
+			default:
+				m.WinnerTo, m.WinnerSlot = lb[j+1][p/2], p%2+1
+			}
+		}
+		if j%2 == 1 {
+			// losers of the winners round meet the losers bracket in reverse order to avoid early rematches
+			wr := wb[j/2+1]
+			for p, number := range wr {
+				m := &matches[number-1]
+				m.LoserTo, m.LoserSlot = lb[j][len(lb[j])-1-p], 2
+			}
+		}
+	}
+	final := &matches[wb[rounds-1][0]-1]
+	final.WinnerTo, final.WinnerSlot = grandFinal, 1
+	return matches
+}
+
+// placeInMatch puts the player into the slot of the match.
+func placeInMatch(matches []entity.Match, number int, slot int, playerID int) {
+	m := &matches[number-1]
+	if slot == 1 {
+		m.Player1 = playerID
+	} else {
+		m.Player2 = playerID
+	}
+	m.Filled++
+}
+
+// completeMatch stores the match winner and moves the winner and the loser on.
+func completeMatch(matches []entity.Match, number int, winner int, loser int) {
+	m := &matches[number-1]
+	m.Winner = winner
+	m.Status = entity.MatchDone
+	if m.WinnerTo > 0 {
+		placeInMatch(matches, m.WinnerTo, m.WinnerSlot, winner)
+	}
+	if m.LoserTo > 0 {
+		placeInMatch(matches, m.LoserTo, m.LoserSlot, loser)
+	}
+}
+
+// settleBracket marks matches with both players ready and completes matches with byes until nothing changes.
+func settleBracket(matches []entity.Match) {
+	for changed := true; changed; {
+		changed = false
+		for i := range matches {
+			m := &matches[i]
+			if m.Status != entity.MatchPending || m.Filled < 2 {
+				continue
+			}
+			changed = true
+			if m.Player1 != 0 && m.Player2 != 0 {
+				m.Status = entity.MatchReady
+				continue
+			}
+			completeMatch(matches, m.Number, m.Player1+m.Player2, 0)
+		}
+	}
+}
+
+// reportBracketMatch records the result of a ready match and advances the players.
+func reportBracketMatch(matches []entity.Match, number int, result entity.MatchResult) error {
+	if number < 1 || number > len(matches) {
+		return fmt.Errorf("match %d does not exist", number)
+	}
+	m := &matches[number-1]
+	if m.Status != entity.MatchReady {
+		return fmt.Errorf("match %d is %s", number, m.Status)
+	}
+	loser := m.Player1
+	switch result.Winner {
+	case m.Player1:
+		loser = m.Player2
+	case m.Player2:
+	default:
+		return fmt.Errorf("user %d does not play match %d", result.Winner, number)
+	}
+	m.Score1, m.Score2 = result.Score1, result.Score2
+	completeMatch(matches, number, result.Winner, loser)
+	settleBracket(matches)
+	return nil
+}
+
+// bracketStandings returns final standings of a completed bracket. The champion is first, other players
+// are placed by the stage they were eliminated at, players eliminated at the same stage share the place.
+func bracketStandings(matches []entity.Match, double bool) ([]entity.Standing, error) {
+	final := finalMatch(matches, double)
+	if final == nil || final.Status != entity.MatchDone {
+		return nil, fmt.Errorf("bracket is not complete")
+	}
+	lbRounds := 0
+	for _, m := range matches {
+		if m.Bracket == entity.BracketLosers && m.Round > lbRounds {
+			lbRounds = m.Round
+		}
+	}
+	type elimination struct{ player, stage int }
+	eliminated := make([]elimination, 0, len(matches))
+	for _, m := range matches {
+		if m.Status != entity.MatchDone || m.Player1 == 0 || m.Player2 == 0 {
+			continue
+		}
+		loser := m.Player1
+		if m.Winner == m.Player1 {
+			loser = m.Player2
+		}
+		switch {
+		case !double:
+			eliminated = append(eliminated, elimination{loser, m.Round})
+		case m.Bracket == entity.BracketLosers:
+			eliminated = append(eliminated, elimination{loser, m.Round})
+		case m.Bracket == entity.BracketGrandFinal:
+			eliminated = append(eliminated, elimination{loser, lbRounds + 1})
+		}
+	}
+	sort.SliceStable(eliminated, func(i, j int) bool { return eliminated[i].stage > eliminated[j].stage })
+	standings := []entity.Standing{{PlayerID: final.Winner, Place: 1}}
+	for i, e := range eliminated {
+		place := i + 2
+		if i > 0 && eliminated[i-1].stage == e.stage {
+			place = standings[len(standings)-1].Place
+		}
+		standings = append(standings, entity.Standing{PlayerID: e.player, Place: place})
+	}
+	return standings, nil
+}
+
+// finalMatch returns the match deciding the champion.
+func finalMatch(matches []entity.Match, double bool) *entity.Match {
+	var final *entity.Match
+	for i := range matches {
+		m := &matches[i]
+		if double && m.Bracket == entity.BracketGrandFinal {
+			return m
+		}
+		if !double && m.Bracket == entity.BracketWinners && (final == nil || m.Round > final.Round) {
+			final = m
+		}
+	}
+	return final
+}
+
+// isBracket reports whether the tournament format is played in an elimination bracket.
+func isBracket(format string) bool {
+	return format == entity.FormatSingleElimination || format == entity.FormatDoubleElimination
+}
+
+// seedPlayers validates the seeding against the participants. Without seeding players are seeded
+// in the order they joined the tournament.
+func seedPlayers(seeds []int, participants []entity.TournamentPlayer) ([]int, error) {
+	if len(seeds) == 0 {
+		seeds = make([]int, 0, len(participants))
+		for _, p := range participants {
+			seeds = append(seeds, p.PlayerID)
+		}
+		return seeds, nil
+	}
+	if len(seeds) != len(participants) {
+		return nil, fmt.Errorf("seeding must contain every participant exactly once")
+	}
+	joined := make(map[int]bool, len(participants))
+	for _, p := range participants {
+		joined[p.PlayerID] = true
+	}
+	for _, id := range seeds {
+		if !joined[id] {
+			return nil, fmt.Errorf("seeding must contain every participant exactly once")
+		}
+		delete(joined, id)
+	}
+	return seeds, nil
+}
+
+// GenerateBracket seeds the participants of a running elimination tournament and generates its matches.
+func GenerateBracket(db *sql.DB, tournamentID int, seeds []int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isBracket(tournament.Format) {
+		return nil, fmt.Errorf("tournament %d is not an elimination tournament", tournamentID)
+	}
+	if tournament.Status != entity.TournamentIsRunning {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	count, err := database.CountMatches(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("bracket of tournament %d is already generated", tournamentID)
+	}
+	participants, err := database.SelectTournamentUsers(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(participants) < 2 {
+		return nil, fmt.Errorf("bracket needs at least two players")
+	}
+	seeds, err = seedPlayers(seeds, participants)
+	if err != nil {
+		return nil, err
+	}
+	matches := buildBracket(tournamentID, seeds, tournament.Format == entity.FormatDoubleElimination)
+	settleBracket(matches)
+	for _, m := range matches {
+		err = database.InsertMatch(tx, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return matchesJSON(matches)
+}
+
+// ReportMatchResult records the result of a tournament match and advances the players.
+func ReportMatchResult(db *sql.DB, tournamentID int, number int, result entity.MatchResult) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status != entity.TournamentIsRunning {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	matches, err := database.SelectMatches(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case isBracket(tournament.Format):
+		err = reportBracketMatch(matches, number, result)
+	case isLeague(tournament.Format):
+		err = reportLeagueMatch(matches, number, result)
+	default:
+		err = fmt.Errorf("tournament %d has no matches", tournamentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		err = database.UpdateMatch(tx, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return matchesJSON(matches)
+}
+
+// GetMatches get tournament matches from database layer.
+func GetMatches(db *sql.DB, tournamentID int) ([]byte, error) {
+	matches, err := database.SelectMatches(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	return matchesJSON(matches)
+}
+
+// matchesJSON converts matches to JSON output.
+func matchesJSON(matches []entity.Match) ([]byte, error) {
+	res := make([]entity.MatchInfo, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, entity.MatchInfo{
+			Number:   m.Number,
+			Bracket:  m.Bracket,
+			Round:    m.Round,
+			Position: m.Position,
+			Player1:  m.Player1,
+			Player2:  m.Player2,
+			Score1:   m.Score1,
+			Score2:   m.Score2,
+			Winner:   m.Winner,
+			Status:   m.Status,
+		})
+	}
+	return json.Marshal(entity.Matches{Matches: res})
+}
@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"math"
 	"time"
 
 	"github.com/mishelini/database"
@@ -12,54 +12,346 @@ import (
 )
 
 // FundPlayer convert player points from float64  to int64 ,
-// and set parameters to database layer.
+// and set parameters to database layer. Raising the balance is a deposit, it is refused
+// when the player is self-excluded or over a deposit limit.
 func FundPlayer(db *sql.DB, id int, points float64) error {
-	return database.FundPlayer(db, id, int64(points*100))
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+	amount := toAmount(points)
+	if deposit := amount - player.Points; deposit > 0 {
+		err = checkLimits(tx, player, entity.LimitDeposit, deposit, time.Now())
+		if err != nil {
+			return err
+		}
+		err = database.InsertPlayerDeposit(tx, id, deposit)
+		if err != nil {
+			return err
+		}
+	}
+	err = database.FundPlayer(tx, id, amount)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// AnnounceTournament  convert tournament deposit from float64  to int64 ,
-// and set parameters to database layer.
-func AnnounceTournament(db *sql.DB, id int, deposit float64) error {
-	return database.AnnounceTournaments(db, id, int64(deposit*100))
+// toPoints convert stored int64 amount to float64 points.
+func toPoints(amount int64) float64 {
+	return float64(amount) / 100
 }
 
-// JoinTournament checks enough points for the user to participate in the tournament adds user to the tournament
+// toAmount convert float64 points to stored int64 amount.
+func toAmount(points float64) int64 {
+	return int64(math.Round(points * 100))
+}
+
+// rake returns the house share of a single entry: the fixed part plus the percentage of the deposit,
+// never more than the deposit itself.
+func rake(tournament entity.Tournament) int64 {
+	r := tournament.RakeFixed + int64(math.Round(float64(tournament.Deposit)*tournament.RakePercent/100))
+	if r > tournament.Deposit {
+		return tournament.Deposit
+	}
+	return r
+}
+
+// AnnounceTournament  convert tournament deposit and rake from float64  to int64 ,
 // and set parameters to database layer.
-func JoinTournament(db *sql.DB, userID int, tournamentID int) error {
-	tournamentData, err := database.SelectTournament(db, tournamentID)
+func AnnounceTournament(db *sql.DB, params entity.AnnounceParams) error {
+	err := validateAnnounceParams(params)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = announce(tx, params)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// validateAnnounceParams checks tournament settings before they are announced or stored in a template.
+func validateAnnounceParams(params entity.AnnounceParams) error {
+	if params.RakePercent < 0 || params.RakePercent > 100 {
+		return fmt.Errorf("rake percent must be between 0 and 100")
+	}
+	if params.RakeFixed < 0 || params.RakeFixed > params.Deposit {
+		return fmt.Errorf("fixed rake must be between 0 and the deposit")
+	}
+	if params.Guarantee < 0 {
+		return fmt.Errorf("guaranteed prize must not be negative")
+	}
+	if params.MinPlayers < 0 || params.MaxPlayers < 0 || params.SwissRounds < 0 {
+		return fmt.Errorf("player limits must not be negative")
+	}
+	if params.MaxReEntries < 0 || params.MaxAddOns < 0 || params.AddOnPrice < 0 {
+		return fmt.Errorf("re-entry and add-on limits must not be negative")
+	}
+	if params.MaxPlayers > 0 && params.MinPlayers > params.MaxPlayers {
+		return fmt.Errorf("min players must not exceed max players")
+	}
+	switch params.Format {
+	case "", entity.FormatRaffle, entity.FormatRanked, entity.FormatSingleElimination, entity.FormatDoubleElimination,
+		entity.FormatRoundRobin, entity.FormatSwiss, entity.FormatLeaderboard:
+	default:
+		return fmt.Errorf("unknown tournament format %q", params.Format)
+	}
+	err := validatePayouts(params.Payouts)
 	if err != nil {
 		return err
 	}
-	userData, err := database.SelectPlayer(db, userID)
+	if params.TeamMode && params.Format != entity.FormatRanked {
+		return fmt.Errorf("team tournaments must be ranked")
+	}
+	if params.TeamMode && (params.MaxReEntries > 0 || params.AddOnPrice > 0) {
+		return fmt.Errorf("team tournaments do not support re-entries and add-ons")
+	}
+	if params.SatelliteFor < 0 || params.SatelliteTickets < 0 {
+		return fmt.Errorf("satellite settings must not be negative")
+	}
+	if (params.SatelliteFor > 0) != (params.SatelliteTickets > 0) {
+		return fmt.Errorf("satellites need a target tournament and the number of tickets")
+	}
+	if params.SatelliteFor > 0 && (params.TeamMode || params.SatelliteFor == params.ID) {
+		return fmt.Errorf("invalid satellite target %d", params.SatelliteFor)
+	}
+	switch params.TicketUnused {
+	case "", entity.TicketExpire, entity.TicketCash:
+	default:
+		return fmt.Errorf("unknown unused ticket policy %q", params.TicketUnused)
+	}
+	switch params.TeamFee {
+	case "", entity.TeamFeeCaptain, entity.TeamFeeSplit:
+	default:
+		return fmt.Errorf("unknown team fee mode %q", params.TeamFee)
+	}
+	switch params.ScoreMode {
+	case "":
+	case entity.ScoreModeBest, entity.ScoreModeSum:
+		if params.Format != entity.FormatLeaderboard {
+			return fmt.Errorf("score mode is only used by leaderboard tournaments")
+		}
+	default:
+		return fmt.Errorf("unknown score mode %q", params.ScoreMode)
+	}
+	if before(params.RegistrationClosesAt, params.RegistrationOpensAt) || before(params.StartsAt, params.RegistrationOpensAt) {
+		return fmt.Errorf("registration must open before it closes and before the tournament starts")
+	}
+	if before(params.StartsAt, params.RegistrationClosesAt) {
+		return fmt.Errorf("registration must close before the tournament starts")
+	}
+	return nil
+}
+
+// announce inserts the tournament together with the server seed of its draw.
+func announce(tx *sql.Tx, params entity.AnnounceParams) error {
+	tournament := entity.Tournament{
+		ID:          params.ID,
+		Deposit:     toAmount(params.Deposit),
+		RakePercent: params.RakePercent,
+		RakeFixed:   toAmount(params.RakeFixed),
+		Guarantee:   toAmount(params.Guarantee),
+		MaxPlayers:  params.MaxPlayers,
+		MinPlayers:  params.MinPlayers,
+
+		RegistrationOpensAt:  params.RegistrationOpensAt,
+		RegistrationClosesAt: params.RegistrationClosesAt,
+		StartsAt:             params.StartsAt,
+
+		Format:      params.Format,
+		Payouts:     params.Payouts,
+		SwissRounds: params.SwissRounds,
+		TeamMode:    params.TeamMode,
+		TeamFee:     params.TeamFee,
+		ScoreMode:   params.ScoreMode,
+
+		MaxReEntries:  params.MaxReEntries,
+		AddOnPrice:    toAmount(params.AddOnPrice),
+		MaxAddOns:     params.MaxAddOns,
+		AddOnClosesAt: params.AddOnClosesAt,
+
+		SatelliteFor:     params.SatelliteFor,
+		SatelliteTickets: params.SatelliteTickets,
+		TicketUnused:     params.TicketUnused,
+	}
+	if tournament.SatelliteFor > 0 {
+		target, err := database.SelectTournament(tx, tournament.SatelliteFor)
+		if err != nil {
+			return fmt.Errorf("satellite target %d: %s", tournament.SatelliteFor, err)
+		}
+		if target.Deposit == 0 || target.Status != entity.TournamentIsAnnounced && target.Status != entity.TournamentIsScheduled {
+			return fmt.Errorf("satellite target %d does not take paid entries", target.ID)
+		}
+	}
+	if tournament.RegistrationOpensAt != nil && tournament.RegistrationOpensAt.After(time.Now()) {
+		tournament.Status = entity.TournamentIsScheduled
+	}
+	seed, hash, err := newServerSeed()
+	if err != nil {
+		return err
+	}
+	err = database.AnnounceTournaments(tx, tournament)
 	if err != nil {
 		return err
 	}
+	return database.SetTournamentServerSeed(tx, tournament.ID, seed, hash)
+}
 
+// GetTournament get tournament from database layer and convert amounts from int64  to float64.
+func GetTournament(db *sql.DB, tournamentID int) ([]byte, error) {
+	tournament, err := database.SelectTournament(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.TournamentInfo{
+		ID:             tournament.ID,
+		Status:         entity.TournamentStatusName(tournament.Status),
+		Format:         tournament.Format,
+		Deposit:        toPoints(tournament.Deposit),
+		Prize:          toPoints(tournament.Prize),
+		Guarantee:      toPoints(tournament.Guarantee),
+		Payouts:        tournament.Payouts,
+		ServerSeedHash: tournament.ServerSeedHash,
+	})
+}
+
+// before reports whether both times are set and a is before b.
+func before(a, b *time.Time) bool {
+	return a != nil && b != nil && a.Before(*b)
+}
+
+// registrationIsOpen checks tournament status and registration window.
+func registrationIsOpen(tournament entity.Tournament, now time.Time) bool {
+	if tournament.Status != entity.TournamentIsAnnounced {
+		return false
+	}
+	if tournament.RegistrationOpensAt != nil && now.Before(*tournament.RegistrationOpensAt) {
+		return false
+	}
+	if tournament.RegistrationClosesAt != nil && !now.Before(*tournament.RegistrationClosesAt) {
+		return false
+	}
+	return true
+}
+
+// JoinTournament checks enough points for the user to participate in the tournament adds user to the tournament
+// and set parameters to database layer. When the tournament is full the user is put on its waitlist instead.
+func JoinTournament(db *sql.DB, userID int, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournamentData, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := database.SelectPlayer(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !registrationIsOpen(tournamentData, time.Now()) {
+		return nil, fmt.Errorf("tournment is closed")
+	}
+	if tournamentData.TeamMode {
+		return nil, fmt.Errorf("tournament %d is joined by teams", tournamentID)
+	}
+	if tournamentData.MaxPlayers > 0 {
+		count, err := database.CountTournamentUsers(tx, tournamentID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= tournamentData.MaxPlayers {
+			return waitlist(tx, tournamentData, userID)
+		}
+	}
 	if userData.Points < tournamentData.Deposit {
-		return fmt.Errorf("user " + string(userID) + " does not have enough points")
+		return nil, fmt.Errorf("user %d does not have enough points", userID)
 	}
-	if tournamentData.Status == entity.TournamentIsFinished {
-		return fmt.Errorf("tournment is closed")
+	err = enterTournament(tx, tournamentData, userID)
+	if err != nil {
+		return nil, err
 	}
-	newUserPoints := userData.Points - tournamentData.Deposit
-	newTormentPrize := tournamentData.Deposit + tournamentData.Prize
-	err = database.ChangeTournamentsPrize(db, tournamentID, newTormentPrize)
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.JoinResults{PlayerID: userID, TournamentID: tournamentID, Status: entity.JoinStatusJoined})
+}
+
+// enterTournament takes the deposit from the player and adds him to the tournament. The rake is taken
+// from the deposit and credited to the house account, the rest goes to the tournament prize.
+func enterTournament(tx *sql.Tx, tournament entity.Tournament, playerID int) error {
+	err := checkSpend(tx, playerID, tournament, tournament.Deposit, time.Now())
 	if err != nil {
 		return err
 	}
-	err = database.FundPlayer(db, userID, newUserPoints)
+	ok, err := database.DebitPlayer(tx, playerID, tournament.Deposit)
 	if err != nil {
 		return err
 	}
-	err = database.InsertUserIntoTournament(db, tournamentID, userID)
+	if !ok {
+		return fmt.Errorf("user %d does not have enough points", playerID)
+	}
+	houseShare := rake(tournament)
+	err = database.AddTournamentPrize(tx, tournament.ID, tournament.Deposit-houseShare)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = database.InsertUserIntoTournament(tx, tournament.ID, playerID, tournament.Deposit, houseShare)
+	if err != nil {
+		return err
+	}
+	err = database.InsertPurchase(tx, entity.Purchase{TournamentID: tournament.ID, PlayerID: playerID,
+		Kind: entity.PurchaseEntry, Amount: tournament.Deposit, Rake: houseShare})
+	if err != nil {
+		return err
+	}
+	return collectRake(tx, tournament.ID, playerID, houseShare)
+}
+
+// collectRake credits the rake paid by the player to the house account.
+func collectRake(tx *sql.Tx, tournamentID int, playerID int, amount int64) error {
+	if amount == 0 {
+		return nil
+	}
+	err := database.ChangeHouseBalance(tx, database.HouseAccountID, amount)
+	if err != nil {
+		return err
+	}
+	return database.InsertHouseLedgerEntry(tx, entity.HouseLedgerEntry{
+		HouseID:      database.HouseAccountID,
+		TournamentID: tournamentID,
+		PlayerID:     playerID,
+		Amount:       amount,
+		Kind:         entity.LedgerKindRake,
+	})
+}
+
+// countEntries counts tournament entries: teams in team mode, players otherwise.
+func countEntries(tx *sql.Tx, tournament entity.Tournament) (int, error) {
+	if tournament.TeamMode {
+		return database.CountTournamentTeams(tx, tournament.ID)
+	}
+	return database.CountTournamentUsers(tx, tournament.ID)
 }
 
 // GetFinishedTournamentSet  get list of finished tournaments from database layer
-// convert tournament prize and player points  from int64  to float64.
+// convert tournament prize from int64  to float64. The results are public, balances of the winners are left out.
 func GetFinishedTournamentSet(db *sql.DB) ([]byte, error) {
 	tournaments, err := database.SelectFinishedTournaments(db)
 	if err != nil {
@@ -71,12 +363,7 @@ func GetFinishedTournamentSet(db *sql.DB) ([]byte, error) {
 	winnersSet := make([]entity.Winner, 0)
 	for i := range tournaments {
 		tournament := tournaments[i]
-		winnerUserID := tournament.Winner
-		player, err := database.SelectPlayer(db, winnerUserID)
-		if err != nil {
-			return nil, err
-		}
-		win := entity.Winner{winnerUserID, float64(tournament.Prize / 100), float64(player.Points / 100)}
+		win := entity.Winner{PlayerID: tournament.Winner, Prize: toPoints(tournament.Prize), Overlay: toPoints(tournament.Overlay)}
 		winnersSet = append(winnersSet, win)
 	}
 	res := entity.Results{
@@ -89,54 +376,179 @@ func GetFinishedTournamentSet(db *sql.DB) ([]byte, error) {
 	return js, err
 }
 
-// FinishTournament checks tournament status and if it is not finished
-// randomly chooses the winner and set parameters to database layer.
-func FinishTournament(db *sql.DB, tournamentID int) ([]byte, error) {
-	var playerBalance int64
-	tournament, err := database.SelectTournament(db, tournamentID)
+// fundOverlay tops the tournament prize up to the guaranteed prize from the house account
+// and records the overlay in the house ledger.
+func fundOverlay(tx *sql.Tx, tournament *entity.Tournament) error {
+	overlay := tournament.Guarantee - tournament.Prize
+	if overlay <= 0 {
+		return nil
+	}
+	err := database.ChangeHouseBalance(tx, database.HouseAccountID, -overlay)
+	if err != nil {
+		return err
+	}
+	err = database.InsertHouseLedgerEntry(tx, entity.HouseLedgerEntry{
+		HouseID:      database.HouseAccountID,
+		TournamentID: tournament.ID,
+		Amount:       -overlay,
+		Kind:         entity.LedgerKindOverlay,
+	})
+	if err != nil {
+		return err
+	}
+	err = database.AddTournamentOverlay(tx, tournament.ID, overlay)
+	if err != nil {
+		return err
+	}
+	tournament.Prize += overlay
+	tournament.Overlay += overlay
+	return nil
+}
+
+// FinishTournament checks tournament status and if it is not finished chooses the winners and pays out the prize.
+// Raffle tournaments draw the winner from the participants, ranked tournaments pay out the prize
+// according to the posted results, elimination, round robin and Swiss tournaments according to
+// their final standings. A tournament without participants, or with fewer than its minimum,
+// can't have a winner: it is cancelled and the entries are refunded instead.
+func FinishTournament(db *sql.DB, tournamentID int, results *entity.TournamentResults) ([]byte, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	if tournament.Status == entity.TournamentIsFinished {
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status == entity.TournamentIsFinished || tournament.Status == entity.TournamentIsCancelled {
 		return nil, fmt.Errorf("tournment is finished")
 	}
-	winnerID := tournament.Winner
-	if winnerID != 0 {
-		winnerPlayer, err := database.SelectPlayer(db, winnerID)
+	clientSeed := ""
+	if results != nil {
+		clientSeed = results.ClientSeed
+	}
+	if len(clientSeed) > entity.MaxClientSeedLength {
+		return nil, fmt.Errorf("client seed must not be longer than %d characters", entity.MaxClientSeedLength)
+	}
+	posted := results != nil && (len(results.Ranking) > 0 || len(results.Scores) > 0)
+	if tournament.TeamMode && tournament.Format != entity.FormatRanked {
+		return nil, fmt.Errorf("team tournament %d must be ranked", tournamentID)
+	}
+	if tournament.Format == entity.FormatRanked && !posted {
+		return nil, fmt.Errorf("tournament %d needs results to be finished", tournamentID)
+	}
+	if tournament.Format != entity.FormatRanked && posted {
+		return nil, fmt.Errorf("tournament %d is %s and does not accept results", tournamentID, tournament.Format)
+	}
+	participants, err := database.SelectTournamentUsers(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.TeamMode {
+		participants, err = teamParticipants(tx, tournamentID)
 		if err != nil {
 			return nil, err
 		}
-		playerBalance = winnerPlayer.Points
-		tournamentPlayerSet, err := database.SelectTournamentUsers(db, tournamentID)
+	}
+	if len(participants) == 0 || len(participants) < tournament.MinPlayers {
+		err = cancelTournament(tx, tournament)
 		if err != nil {
 			return nil, err
 		}
-		if len(tournamentPlayerSet) > 0 {
-			var totalUsers []int
-			for i := 0; i < len(tournamentPlayerSet); i++ {
-				playerID := tournamentPlayerSet[i].PlayerID
-				totalUsers = append(totalUsers, playerID)
-			}
-			rand.Seed(time.Now().Unix())
-			winnerID = totalUsers[rand.Intn(len(totalUsers))]
-
-			winnerPlayer, err := database.SelectPlayer(db, winnerID)
-			if err != nil {
-				return nil, err
-			}
-			playerBalance = tournament.Prize + winnerPlayer.Points
-			err = database.FundPlayer(db, winnerID, playerBalance)
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
 		}
+		return json.Marshal(entity.TournamentState{
+			TournamentID: tournamentID,
+			Status:       entity.TournamentStatusName(entity.TournamentIsCancelled),
+			Players:      len(participants),
+		})
 	}
 
-	err = database.FinishTournament(db, tournamentID, winnerID)
+	err = fundOverlay(tx, &tournament)
+	if err != nil {
+		return nil, err
+	}
+	var ranked []entity.Standing
+	switch {
+	case tournament.Format == entity.FormatRanked:
+		ranked, err = rankResults(*results, participants)
+		if err != nil {
+			return nil, err
+		}
+		distributePrize(tournament.Prize, tournament.Payouts, ranked)
+	case isBracket(tournament.Format):
+		matches, err := database.SelectMatches(tx, tournamentID)
+		if err != nil {
+			return nil, err
+		}
+		ranked, err = bracketStandings(matches, tournament.Format == entity.FormatDoubleElimination)
+		if err != nil {
+			return nil, err
+		}
+		distributePrize(tournament.Prize, tournament.Payouts, ranked)
+	case isLeague(tournament.Format):
+		matches, err := database.SelectMatches(tx, tournamentID)
+		if err != nil {
+			return nil, err
+		}
+		ranked, err = leagueFinalStandings(tournament, participants, matches)
+		if err != nil {
+			return nil, err
+		}
+		distributePrize(tournament.Prize, tournament.Payouts, ranked)
+	case tournament.Format == entity.FormatLeaderboard:
+		ranked, err = leaderboardStandings(tx, tournament, participants)
+		if err != nil {
+			return nil, err
+		}
+		distributePrize(tournament.Prize, tournament.Payouts, ranked)
+	default:
+		ranked, err = drawRaffle(tx, tournament, participants, clientSeed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tournament.TeamMode {
+		ranked, err = payTeams(tx, tournamentID, ranked)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tournament.SatelliteFor > 0 {
+		ranked, err = awardTickets(tx, tournament, ranked)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = settleTickets(tx, tournamentID, false)
 	if err != nil {
 		return nil, err
 	}
-	win := entity.Winner{winnerID, float64(tournament.Prize / 100), float64(playerBalance / 100)}
+	err = payStandings(tx, tournamentID, ranked)
+	if err != nil {
+		return nil, err
+	}
+	err = database.FinishTournament(tx, tournamentID, ranked[0].PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	standings, err := standingResults(tx, ranked)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	win := entity.Winner{PlayerID: standings[0].PlayerID, Prize: toPoints(tournament.Prize), Balance: standings[0].Balance,
+		Overlay: toPoints(tournament.Overlay)}
 
 	res := entity.Result{
-		Winner: win,
+		Winner:    win,
+		Standings: standings,
 	}
 	js, err := json.Marshal(res)
 	if err != nil {
@@ -145,6 +557,148 @@ func FinishTournament(db *sql.DB, tournamentID int) ([]byte, error) {
 	return js, nil
 }
 
+// drawRaffle draws the raffle winner, who takes the whole prize, and stores the draw inputs for verification.
+func drawRaffle(tx *sql.Tx, tournament entity.Tournament, participants []entity.TournamentPlayer, clientSeed string) ([]entity.Standing, error) {
+	players := drawPlayers(participants)
+	draw, err := database.SelectTournamentDraw(tx, tournament.ID)
+	if err != nil {
+		return nil, err
+	}
+	winnerID := drawWinner(draw.ServerSeed, clientSeed, players)
+	err = database.SaveTournamentDraw(tx, tournament.ID, clientSeed, players)
+	if err != nil {
+		return nil, err
+	}
+	return []entity.Standing{{PlayerID: winnerID, Place: 1, Prize: tournament.Prize}}, nil
+}
+
+// StartTournament closes registration of the announced tournament and starts it. A tournament which has not reached
+// its minimum number of players is cancelled and all entries are refunded.
+func StartTournament(db *sql.DB, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status != entity.TournamentIsAnnounced && tournament.Status != entity.TournamentIsRegistrationClosed {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	count, err := countEntries(tx, tournament)
+	if err != nil {
+		return nil, err
+	}
+	status := entity.TournamentIsRunning
+	if count < tournament.MinPlayers {
+		status = entity.TournamentIsCancelled
+		err = cancelTournament(tx, tournament)
+	} else {
+		err = database.ClearWaitlist(tx, tournamentID)
+		if err == nil {
+			err = settleTickets(tx, tournamentID, false)
+		}
+		if err == nil {
+			err = database.ChangeTournamentStatus(tx, tournamentID, status)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.TournamentState{
+		TournamentID: tournamentID,
+		Status:       entity.TournamentStatusName(status),
+		Players:      count,
+	})
+}
+
+// cancelTournament refunds all entries, drops the waitlist and marks the tournament cancelled.
+func cancelTournament(tx *sql.Tx, tournament entity.Tournament) error {
+	err := refundTournament(tx, tournament)
+	if err != nil {
+		return err
+	}
+	err = database.ClearWaitlist(tx, tournament.ID)
+	if err != nil {
+		return err
+	}
+	err = settleTickets(tx, tournament.ID, true)
+	if err != nil {
+		return err
+	}
+	return database.ChangeTournamentStatus(tx, tournament.ID, entity.TournamentIsCancelled)
+}
+
+// refundTournament returns the paid deposits to all tournament players,
+// takes the rake back from the house account and empties the prize.
+func refundTournament(tx *sql.Tx, tournament entity.Tournament) error {
+	players, err := database.SelectTournamentUsers(tx, tournament.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range players {
+		_, err = refundEntry(tx, p)
+		if err != nil {
+			return err
+		}
+	}
+	return database.ChangeTournamentsPrize(tx, tournament.ID, 0)
+}
+
+// refundEntry returns the paid deposit to the player and takes the rake back from the house account.
+// An entry paid with a ticket gives the ticket back instead, re-entries and add-ons bought on top of it
+// are refunded in points. It returns the points refunded.
+// The account status is not checked: a refund only gives back what the player paid in, so suspended,
+// self-excluded and closed accounts get their entries back too, withdrawals stay blocked by checkAccount.
+func refundEntry(tx *sql.Tx, entry entity.TournamentPlayer) (int64, error) {
+	refund := entry.Paid
+	if entry.TicketID != 0 {
+		ticket, err := database.SelectTicketByIDForUpdate(tx, entry.TicketID)
+		if err != nil {
+			return 0, err
+		}
+		err = database.ChangeTicketStatus(tx, entry.TicketID, entity.TicketIssued)
+		if err != nil {
+			return 0, err
+		}
+		refund -= ticket.Value
+	}
+	if refund > 0 {
+		err := database.AddPlayerPoints(tx, entry.PlayerID, refund)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if refund > 0 || entry.Rake > 0 {
+		err := database.InsertPurchase(tx, entity.Purchase{TournamentID: entry.TournamentID, PlayerID: entry.PlayerID,
+			Kind: entity.PurchaseRefund, Amount: -refund, Rake: -entry.Rake})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if entry.Rake == 0 {
+		return refund, nil
+	}
+	err := database.ChangeHouseBalance(tx, database.HouseAccountID, -entry.Rake)
+	if err != nil {
+		return 0, err
+	}
+	return refund, database.InsertHouseLedgerEntry(tx, entity.HouseLedgerEntry{
+		HouseID:      database.HouseAccountID,
+		TournamentID: entry.TournamentID,
+		PlayerID:     entry.PlayerID,
+		Amount:       -entry.Rake,
+		Kind:         entity.LedgerKindRefund,
+	})
+}
+
 // GetUserBalance  get user from database layer and convert user balanse from int64  to float64.
 func GetUserBalance(db *sql.DB, id int) ([]byte, error) {
 	player, err := database.SelectPlayer(db, id)
@@ -153,8 +707,35 @@ func GetUserBalance(db *sql.DB, id int) ([]byte, error) {
 	}
 	res2 := entity.BalanceResults{
 		PlayerId: id,
-		Balance:  float64(player.Points / 100),
+		Balance:  toPoints(player.Points),
 	}
 	js, err := json.Marshal(res2)
 	return js, err
 }
+
+// GetHouseAccount get house account balance and ledger from database layer
+// and convert amounts from int64  to float64.
+func GetHouseAccount(db *sql.DB) ([]byte, error) {
+	house, err := database.SelectHouseAccount(db, database.HouseAccountID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := database.SelectHouseLedger(db, database.HouseAccountID)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.HouseResults{
+		Balance: toPoints(house.Balance),
+		Ledger:  make([]entity.LedgerEntry, 0, len(entries)),
+	}
+	for _, e := range entries {
+		res.Ledger = append(res.Ledger, entity.LedgerEntry{
+			TournamentID: e.TournamentID,
+			PlayerID:     e.PlayerID,
+			Amount:       toPoints(e.Amount),
+			Kind:         e.Kind,
+			CreatedAt:    e.CreatedAt,
+		})
+	}
+	return json.Marshal(res)
+}
@@ -2,9 +2,15 @@ package controller
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/mishelini/database"
 	"github.com/mishelini/entity"
 	"github.com/stretchr/testify/assert"
 	// Pure Go Postgres driver for database/sql
@@ -36,46 +42,14 @@ func fundPlayer(db *sql.DB, playerID int, points int64) error {
 	return err
 }
 func getDBConnection() (*sql.DB, error) {
-	postgresConfig := fmt.Sprintf("host=%s port=%s   user=%s dbname=%s sslmode=%s  password=%s",
-		"localhost", "5432", "postgres", "postgres", "disable", "postgres")
+	postgresConfig := fmt.Sprintf("host=%s port=%s   user=%s dbname=%s sslmode=%s  password=%s search_path=%s",
+		"localhost", "5432", "postgres", "postgres", "disable", "postgres", "test_schema")
 	dbConn, err := sql.Open("postgres", postgresConfig)
 	return dbConn, err
 }
 func initTestDb(db *sql.DB) error {
-	new_schema_strings := []string{
-		`CREATE TABLE IF NOT EXISTS player
-		(
-		   id        SERIAL PRIMARY KEY,
-		   first_name VARCHAR(30),
-		   points     BIGINT DEFAULT 0
-		);`,
-		` CREATE TABLE IF NOT EXISTS tournament
-		(
-		   id      SERIAL NOT NULL PRIMARY KEY,
-		   deposit BIGINT NOT NULL DEFAULT 0,
-		   prize   BIGINT NOT NULL DEFAULT 0,
-		   status   INT DEFAULT 0,
-		   winner   INT DEFAULT 0
-		   
-		);`,
-		` CREATE TABLE IF NOT EXISTS tournament_player
-		(
-		   player_id     INT REFERENCES player (id) ON UPDATE CASCADE ON DELETE
-		   CASCADE,
-		   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
-		   CONSTRAINT tournament_player_pkey PRIMARY KEY (player_id, tournament_id)
-		);`,
-		` INSERT INTO player (first_name, points) VALUES ('testuser', 0);`,
-		` INSERT INTO player (first_name, points) VALUES ('testuser2', 0);`,
-	}
-	for _, qstr := range new_schema_strings {
-		_, err := db.Exec(qstr)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-
+	database.InitData = true
+	return database.CreateTablesIfNotExist(db)
 }
 
 func prepareTestEnv() (*sql.DB, error) {
@@ -112,7 +86,7 @@ func insertUserIntoTournament(db *sql.DB, userID int, tournamentID int) error {
 }
 func selectFinishedTournaments(db *sql.DB) ([]entity.Tournament, error) {
 	tournaments := make([]entity.Tournament, 0)
-	rows, err := db.Query(`SELECT * FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
+	rows, err := db.Query(`SELECT id, deposit, prize, winner, status FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +130,7 @@ func TestFundPlayer(t *testing.T) {
 	assert.NoError(t, err, "func initTestDb failed")
 	err = FundPlayer(db, testUser.ID, float64(testUser.Points))
 	assert.NoError(t, err, "fuc FundPlayer return error")
-	row := db.QueryRow("SELECT * FROM player WHERE id = $1 ", testUser.ID)
+	row := db.QueryRow("SELECT id, first_name, points FROM player WHERE id = $1 ", testUser.ID)
 	err = row.Scan(&player.ID, &player.FirstName, &player.Points)
 	assert.NoError(t, err, "select player return error")
 	assert.Equal(t, testUser.Points, player.Points/100, "player points after funding should be equal")
@@ -170,9 +144,9 @@ func TestAnnounceTournaments(t *testing.T) {
 	assert.NoError(t, err, "func prepareTestEnv failed")
 	defer db.Close()
 
-	err = AnnounceTournament(db, testTournament.ID, float64(testTournament.Deposit))
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: float64(testTournament.Deposit)})
 	assert.NoError(t, err, "func AnnounceTournaments failed")
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", testTournament.ID)
+	row := db.QueryRow("SELECT id, deposit, prize, winner, status FROM tournament WHERE id = $1 ", testTournament.ID)
 	err = row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
 	assert.NoError(t, err, "select tournament return error")
 	assert.Equal(t, testTournament.ID, tournament.ID, "no test tournament in db")
@@ -191,18 +165,923 @@ func TestJoinTournament(t *testing.T) {
 	assert.NoError(t, err, "func fundPlayer failed")
 	err = announceTestTournament(db, testTournament.ID, testTournament.Deposit)
 	assert.NoError(t, err, "func announceTestTournament failed")
-	err = JoinTournament(db, testUser.ID, testTournament.ID)
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
 	assert.NoError(t, err, "func InsertUserIntoTournament failed")
 
-	row := db.QueryRow("SELECT * FROM tournament_player WHERE tournament_id = $1 ", testTournament.ID)
+	row := db.QueryRow("SELECT player_id, tournament_id FROM tournament_player WHERE tournament_id = $1 ", testTournament.ID)
 	err = row.Scan(&tournamentPlayer.PlayerID, &tournamentPlayer.TournamentID)
 	assert.NoError(t, err, "select tournament return error")
 	assert.Equal(t, testUser.ID, tournamentPlayer.PlayerID, "no user in tournament")
 
-	row = db.QueryRow("SELECT * FROM player WHERE id = $1 ", testUser.ID)
+	row = db.QueryRow("SELECT id, first_name, points FROM player WHERE id = $1 ", testUser.ID)
 	err = row.Scan(&player.ID, &player.FirstName, &player.Points)
 	assert.Equal(t, player.Points, testUser.Points-testTournament.Deposit, "test tournament not selected")
 
 	err = dropTestSchema(db)
 	assert.NoError(t, err, "func dropTestSchema faild")
 }
+
+func TestJoinTournamentRake(t *testing.T) {
+	var prize, houseBalance int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 2, RakePercent: 10, RakeFixed: 0.1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+
+	err = db.QueryRow("SELECT prize FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&prize)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, int64(170), prize, "prize should be net of rake")
+	err = db.QueryRow("SELECT balance FROM house_account WHERE id = 1").Scan(&houseBalance)
+	assert.NoError(t, err, "select house account return error")
+	assert.Equal(t, int64(30), houseBalance, "rake should be credited to the house")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestFinishTournamentOverlay(t *testing.T) {
+	var prize, overlay, houseBalance int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 2, Guarantee: 10})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT prize, overlay FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&prize, &overlay)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, int64(1000), prize, "prize should be topped up to the guarantee")
+	assert.Equal(t, int64(800), overlay, "overlay should be recorded")
+	err = db.QueryRow("SELECT balance FROM house_account WHERE id = 1").Scan(&houseBalance)
+	assert.NoError(t, err, "select house account return error")
+	assert.Equal(t, int64(-800), houseBalance, "overlay should be funded by the house")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestJoinTournamentFull(t *testing.T) {
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, MaxPlayers: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	js, err := JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "join of a full tournament should waitlist the player")
+	assert.Contains(t, string(js), entity.JoinStatusWaitlisted, "player should be waitlisted")
+	count := 0
+	err = db.QueryRow("SELECT count(*) FROM tournament_player WHERE tournament_id = $1", testTournament.ID).Scan(&count)
+	assert.NoError(t, err, "select tournament_player return error")
+	assert.Equal(t, 1, count, "join beyond capacity should not add a player")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestStartTournamentBelowMinimum(t *testing.T) {
+	var player entity.Player
+	var status int
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, MinPlayers: 2, RakePercent: 10})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = StartTournament(db, testTournament.ID)
+	assert.NoError(t, err, "func StartTournament failed")
+
+	err = db.QueryRow("SELECT status FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&status)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, entity.TournamentIsCancelled, status, "tournament below minimum should be cancelled")
+	row := db.QueryRow("SELECT id, first_name, points FROM player WHERE id = $1 ", testUser.ID)
+	err = row.Scan(&player.ID, &player.FirstName, &player.Points)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points, player.Points, "deposit should be refunded")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestLeaveTournamentPromotesWaitlist(t *testing.T) {
+	var player entity.Player
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, MaxPlayers: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = LeaveTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func LeaveTournament failed")
+
+	row := db.QueryRow("SELECT player_id FROM tournament_player WHERE tournament_id = $1 ", testTournament.ID)
+	err = row.Scan(&player.ID)
+	assert.NoError(t, err, "select tournament_player return error")
+	assert.Equal(t, testUser2.ID, player.ID, "waitlisted player should be promoted")
+	row = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID)
+	err = row.Scan(&player.Points)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser2.Points-100, player.Points, "promoted player should pay the deposit")
+	row = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID)
+	err = row.Scan(&player.Points)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points, player.Points, "leaving player should be refunded")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestFinishRankedTournament(t *testing.T) {
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, Format: entity.FormatRanked,
+		Payouts: []float64{70, 30}})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+
+	_, err = FinishTournament(db, testTournament.ID, &entity.TournamentResults{Ranking: []int{testUser2.ID, 99}})
+	assert.Error(t, err, "ranking of a non participant should be rejected")
+	_, err = FinishTournament(db, testTournament.ID, &entity.TournamentResults{Scores: map[int]float64{testUser.ID: 10, testUser2.ID: 20}})
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points-100+60, points1, "second place should get 30 percent")
+	assert.Equal(t, testUser2.Points-100+140, points2, "first place should get 70 percent")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestTeamTournamentSplit(t *testing.T) {
+	var team entity.TeamInfo
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	js, err := CreateTeam(db, "team", testUser.ID)
+	assert.NoError(t, err, "func CreateTeam failed")
+	assert.NoError(t, json.Unmarshal(js, &team), "team output should be JSON")
+	_, err = AddTeamMember(db, team.ID, testUser2.ID, testUser2.ID, 3)
+	assert.Error(t, err, "only the captain should add members")
+	_, err = AddTeamMember(db, team.ID, testUser.ID, testUser2.ID, math.Inf(1))
+	assert.Error(t, err, "share should be finite")
+	_, err = AddTeamMember(db, team.ID, testUser.ID, testUser2.ID, 3)
+	assert.NoError(t, err, "func AddTeamMember failed")
+	_, err = AcceptTeamInvite(db, team.ID, testUser2.ID)
+	assert.NoError(t, err, "func AcceptTeamInvite failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 2, Format: entity.FormatRanked,
+		TeamMode: true, TeamFee: entity.TeamFeeSplit})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.Error(t, err, "players should not join team tournaments alone")
+	_, err = JoinTeamTournament(db, team.ID, testUser2.ID, testTournament.ID)
+	assert.Error(t, err, "only the captain should enter the team")
+	_, err = JoinTeamTournament(db, team.ID, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTeamTournament failed")
+	_, err = AddTeamMember(db, team.ID, testUser.ID, testUser2.ID+1, 1)
+	assert.Error(t, err, "roster should not change while the team is entered")
+	_, err = db.Exec("UPDATE team_member SET share = 1 WHERE team_id = $1", team.ID)
+	assert.NoError(t, err, "update team member return error")
+
+	_, err = FinishTournament(db, testTournament.ID, &entity.TournamentResults{Ranking: []int{team.ID}})
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points-100+50, points1, "captain should pay half and get a quarter of the prize")
+	assert.Equal(t, testUser2.Points-100+150, points2, "member should pay half and get three quarters of the prize")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestTeamFees(t *testing.T) {
+	team := entity.Team{ID: 1, CaptainID: 2}
+	members := []entity.TeamMember{{TeamID: 1, PlayerID: 1}, {TeamID: 1, PlayerID: 2}, {TeamID: 1, PlayerID: 3}}
+	fees, rakes := teamFees(entity.Tournament{Deposit: 100, TeamFee: entity.TeamFeeSplit}, 10, team, members)
+	assert.Equal(t, map[int]int64{1: 33, 2: 34, 3: 33}, fees, "captain should pay the remainder of the split")
+	assert.Equal(t, map[int]int64{1: 3, 2: 4, 3: 3}, rakes, "captain should pay the remainder of the rake")
+	fees, _ = teamFees(entity.Tournament{Deposit: 100, TeamFee: entity.TeamFeeCaptain}, 10, team, members)
+	assert.Equal(t, map[int]int64{2: 100}, fees, "captain should pay the whole deposit")
+}
+
+func TestLeaderboardTournament(t *testing.T) {
+	var board entity.Leaderboard
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, Format: entity.FormatLeaderboard,
+		ScoreMode: entity.ScoreModeSum})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = SubmitScore(db, testTournament.ID, testUser.ID, 10)
+	assert.Error(t, err, "scores should be accepted only while the tournament is running")
+	_, err = StartTournament(db, testTournament.ID)
+	assert.NoError(t, err, "func StartTournament failed")
+
+	_, err = SubmitScore(db, testTournament.ID, testUser.ID, 30)
+	assert.NoError(t, err, "func SubmitScore failed")
+	_, err = SubmitScore(db, testTournament.ID, testUser2.ID, 20)
+	assert.NoError(t, err, "func SubmitScore failed")
+	js, err := SubmitScore(db, testTournament.ID, testUser2.ID, 15)
+	assert.NoError(t, err, "func SubmitScore failed")
+	assert.NoError(t, json.Unmarshal(js, &board), "leaderboard output should be JSON")
+	assert.Equal(t, testUser2.ID, board.Entries[0].PlayerID, "scores should be summed up")
+	assert.Equal(t, 35.0, board.Entries[0].Score, "scores should be summed up")
+
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points-100, points1, "second place should get nothing")
+	assert.Equal(t, testUser2.Points-100+200, points2, "leaderboard leader should get the prize")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestReEntryAndAddOn(t *testing.T) {
+	var prize, points int64
+	var entries, addOns int
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 0.5, RakePercent: 10,
+		MaxReEntries: 1, AddOnPrice: 0.2, MaxAddOns: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = ReEnterTournament(db, testUser.ID, testTournament.ID)
+	assert.Error(t, err, "only players who joined may re-enter")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = ReEnterTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func ReEnterTournament failed")
+	_, err = ReEnterTournament(db, testUser.ID, testTournament.ID)
+	assert.Error(t, err, "re-entry limit should be enforced")
+	_, err = BuyAddOn(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func BuyAddOn failed")
+	_, err = BuyAddOn(db, testUser.ID, testTournament.ID)
+	assert.Error(t, err, "add-on limit should be enforced")
+
+	err = db.QueryRow("SELECT entries, add_ons FROM tournament_player WHERE tournament_id = $1 AND player_id = $2",
+		testTournament.ID, testUser.ID).Scan(&entries, &addOns)
+	assert.NoError(t, err, "select tournament player return error")
+	assert.Equal(t, 2, entries, "entries mismatch")
+	assert.Equal(t, 1, addOns, "add-ons mismatch")
+	err = db.QueryRow("SELECT prize FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&prize)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, int64(45+45+18), prize, "every purchase should add to the prize pool")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points-120, points, "every purchase should be charged")
+	err = db.QueryRow("SELECT count(*) FROM tournament_purchase WHERE tournament_id = $1", testTournament.ID).Scan(&entries)
+	assert.NoError(t, err, "select purchases return error")
+	assert.Equal(t, 3, entries, "every purchase should be recorded in the ledger")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestResponsibleGamingLimits(t *testing.T) {
+	var limits entity.PlayerLimits
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	for _, id := range []int{1, 2} {
+		err = AnnounceTournament(db, entity.AnnounceParams{ID: id, Deposit: 1})
+		assert.NoError(t, err, "func AnnounceTournament failed")
+	}
+	_, err = SetPlayerLimit(db, testUser.ID, entity.LimitSpend, entity.LimitDaily, 1.5)
+	assert.NoError(t, err, "func SetPlayerLimit failed")
+	_, err = JoinTournament(db, testUser.ID, 1)
+	assert.NoError(t, err, "entry within the limit should be accepted")
+	_, err = JoinTournament(db, testUser.ID, 2)
+	assert.Error(t, err, "entry over the spend limit should be refused")
+
+	js, err := SetPlayerLimit(db, testUser.ID, entity.LimitSpend, entity.LimitDaily, 10)
+	assert.NoError(t, err, "func SetPlayerLimit failed")
+	assert.NoError(t, json.Unmarshal(js, &limits), "unmarshal limits failed")
+	assert.Equal(t, 1.5, limits.Limits[0].Amount, "raised limit should wait for the cooling-off delay")
+	assert.NotNil(t, limits.Limits[0].PendingAmount, "raised limit should be pending")
+	assert.Equal(t, 1.0, limits.Limits[0].Used, "used amount mismatch")
+	js, err = SetPlayerLimit(db, testUser.ID, entity.LimitSpend, entity.LimitDaily, 1)
+	assert.NoError(t, err, "func SetPlayerLimit failed")
+	assert.NoError(t, json.Unmarshal(js, &limits), "unmarshal limits failed")
+	assert.Equal(t, 1.0, limits.Limits[0].Amount, "lowered limit should take effect at once")
+	assert.Nil(t, limits.Limits[0].PendingAmount, "lowering should drop the pending raise")
+	_, err = LeaveTournament(db, testUser.ID, 1)
+	assert.NoError(t, err, "func LeaveTournament failed")
+	_, err = JoinTournament(db, testUser.ID, 2)
+	assert.NoError(t, err, "refunded entry should not count against the spend limit")
+
+	_, err = SetPlayerLimit(db, testUser.ID, entity.LimitDeposit, entity.LimitWeekly, 5)
+	assert.NoError(t, err, "func SetPlayerLimit failed")
+	err = FundPlayer(db, testUser.ID, 6)
+	assert.NoError(t, err, "deposit within the limit should be accepted")
+	err = FundPlayer(db, testUser.ID, 8)
+	assert.Error(t, err, "deposit over the limit should be refused")
+	err = FundPlayer(db, testUser.ID, 3)
+	assert.NoError(t, err, "lowering the balance is not a deposit")
+
+	_, err = SelfExclude(db, testUser.ID, 7)
+	assert.NoError(t, err, "func SelfExclude failed")
+	_, err = SelfExclude(db, testUser.ID, 1)
+	assert.Error(t, err, "self-exclusion should not be shortened")
+	err = FundPlayer(db, testUser.ID, 4)
+	assert.Error(t, err, "self-excluded player should not be funded")
+	_, err = LeaveTournament(db, testUser.ID, 2)
+	assert.NoError(t, err, "self-excluded player may still leave")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestAccountStatus(t *testing.T) {
+	var account entity.AccountInfo
+	var points int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+	defer func(policy string) { SuspendedEntryPolicy = policy }(SuspendedEntryPolicy)
+	SuspendedEntryPolicy = entity.EntryPolicyRefund
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountSuspended, "", "admin:1")
+	assert.Error(t, err, "status change needs a reason")
+	js, err := SetAccountStatus(db, testUser.ID, entity.AccountSuspended, "chargeback", "admin:1")
+	assert.NoError(t, err, "func SetAccountStatus failed")
+	assert.NoError(t, json.Unmarshal(js, &account), "unmarshal account failed")
+	assert.Equal(t, entity.AccountSuspended, account.Status, "status mismatch")
+	assert.Equal(t, []int{testTournament.ID}, account.RefundedEntries, "open entry should be refunded")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points, points, "refund mismatch")
+
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.Error(t, err, "suspended player should not join")
+	err = FundPlayer(db, testUser.ID, 10)
+	assert.Error(t, err, "suspended player should not be funded")
+
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountActive, "chargeback resolved", "admin:1")
+	assert.NoError(t, err, "func SetAccountStatus failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "active player should join again")
+
+	_, err = SelfExclude(db, testUser.ID, 7)
+	assert.NoError(t, err, "func SelfExclude failed")
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountActive, "asked to play", "admin:1")
+	assert.Error(t, err, "self-exclusion should not be lifted before its end")
+
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountClosed, "closed on request", "admin:1")
+	assert.NoError(t, err, "func SetAccountStatus failed")
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountActive, "reopen", "admin:1")
+	assert.Error(t, err, "closed account should not be reopened")
+	js, err = GetAccount(db, testUser.ID)
+	assert.NoError(t, err, "func GetAccount failed")
+	assert.NoError(t, json.Unmarshal(js, &account), "unmarshal account failed")
+	assert.Equal(t, 4, len(account.History), "every status change should be recorded")
+	assert.Equal(t, entity.AccountClosed, account.History[0].Status, "newest change should come first")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestRefundInactiveAccount(t *testing.T) {
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+	defer func(policy string) { SuspendedEntryPolicy = policy }(SuspendedEntryPolicy)
+	SuspendedEntryPolicy = entity.EntryPolicyKeep
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, MinPlayers: 2})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+
+	_, err = SetAccountStatus(db, testUser.ID, entity.AccountSuspended, "chargeback", "admin:1")
+	assert.NoError(t, err, "func SetAccountStatus failed")
+	_, err = LeaveTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "suspended player should still leave")
+	_, err = SetAccountStatus(db, testUser2.ID, entity.AccountSuspended, "chargeback", "admin:1")
+	assert.NoError(t, err, "func SetAccountStatus failed")
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.NoError(t, err, "tournament below its minimum should be cancelled")
+
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points, points1, "suspended player leaving should be refunded")
+	assert.Equal(t, testUser2.Points, points2, "suspended player should be refunded when the tournament is cancelled")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestKYCVerification(t *testing.T) {
+	var kyc entity.KYCInfo
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+	defer func(threshold float64) { KYCDepositThreshold = threshold }(KYCDepositThreshold)
+	KYCDepositThreshold = 1
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: 1, Deposit: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: 2, Deposit: 0.5})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, 2)
+	assert.NoError(t, err, "tournament up to the threshold should be open to unverified players")
+
+	_, err = Withdraw(db, testUser.ID, 0.1)
+	assert.Error(t, err, "unverified player should not withdraw")
+	_, err = ReviewKYC(db, testUser.ID, entity.KYCVerified, "", "admin:1")
+	assert.Error(t, err, "player without documents can't be reviewed")
+	js, err := SubmitKYCDocument(db, testUser.ID, entity.DocumentPassport, "kyc/1/passport.pdf")
+	assert.NoError(t, err, "func SubmitKYCDocument failed")
+	assert.NoError(t, json.Unmarshal(js, &kyc), "unmarshal kyc failed")
+	assert.Equal(t, entity.KYCPending, kyc.Status, "submitted document should wait for a review")
+	assert.Equal(t, 1, len(kyc.Documents), "document should be stored")
+
+	_, err = ReviewKYC(db, testUser.ID, entity.KYCRejected, "", "admin:1")
+	assert.Error(t, err, "rejection needs a note")
+	_, err = ReviewKYC(db, testUser.ID, entity.KYCRejected, "blurred scan", "admin:1")
+	assert.NoError(t, err, "func ReviewKYC failed")
+	_, err = JoinTournament(db, testUser.ID, 1)
+	assert.Error(t, err, "tournament over the threshold should need verification")
+
+	_, err = SubmitKYCDocument(db, testUser.ID, entity.DocumentPassport, "kyc/1/passport-2.pdf")
+	assert.NoError(t, err, "func SubmitKYCDocument failed")
+	js, err = ReviewKYC(db, testUser.ID, entity.KYCVerified, "", "admin:1")
+	assert.NoError(t, err, "func ReviewKYC failed")
+	assert.NoError(t, json.Unmarshal(js, &kyc), "unmarshal kyc failed")
+	assert.Equal(t, entity.KYCVerified, kyc.Status, "status mismatch")
+	assert.Equal(t, "admin:1", kyc.Reviewer, "reviewer mismatch")
+	_, err = JoinTournament(db, testUser.ID, 1)
+	assert.NoError(t, err, "verified player should join")
+
+	_, err = Withdraw(db, testUser.ID, 1)
+	assert.Error(t, err, "withdrawal over the balance should be refused")
+	_, err = Withdraw(db, testUser.ID, 0.5)
+	assert.NoError(t, err, "verified player should withdraw")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2020, time.January, 31, 10, 20, 0, 0, time.UTC)
+	schedule, err := parseCron("@hourly")
+	assert.NoError(t, err, "func parseCron failed")
+	next, ok := schedule.next(from)
+	assert.True(t, ok, "hourly rule should match")
+	assert.Equal(t, time.Date(2020, time.January, 31, 11, 0, 0, 0, time.UTC), next, "next hourly start mismatch")
+
+	schedule, err = parseCron("*/15 9-17 * * 1-5")
+	assert.NoError(t, err, "func parseCron failed")
+	next, _ = schedule.next(time.Date(2020, time.February, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, time.February, 3, 9, 0, 0, 0, time.UTC), next, "weekend should be skipped")
+
+	schedule, err = parseCron("0 0 30 2 *")
+	assert.NoError(t, err, "func parseCron failed")
+	_, ok = schedule.next(from)
+	assert.False(t, ok, "February 30th never comes")
+
+	_, err = parseCron("61 * * * *")
+	assert.Error(t, err, "minute out of range should be rejected")
+	_, err = parseCron("* * *")
+	assert.Error(t, err, "rule with missing fields should be rejected")
+}
+
+func TestRunTemplates(t *testing.T) {
+	var tournament entity.Tournament
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	_, err = CreateTemplate(db, entity.Template{Name: "hourly", Recurrence: "@hourly", LeadMinutes: 30,
+		Params: entity.AnnounceParams{StartsAt: &time.Time{}}})
+	assert.Error(t, err, "template times should be given by the recurrence rule")
+	_, err = CreateTemplate(db, entity.Template{Name: "hourly", Recurrence: "@hourly", LeadMinutes: 60,
+		Params: entity.AnnounceParams{Deposit: 1, MaxPlayers: 8}})
+	assert.NoError(t, err, "func CreateTemplate failed")
+
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+
+	ids, err := RunTemplates(db, time.Now())
+	assert.NoError(t, err, "func RunTemplates failed")
+	assert.Equal(t, 1, len(ids), "due template should announce a tournament")
+	assert.True(t, ids[0] > testTournament.ID, "template should not reuse the id of an announced tournament")
+	ids2, err := RunTemplates(db, time.Now())
+	assert.NoError(t, err, "func RunTemplates failed")
+	assert.Equal(t, 0, len(ids2), "the next tournament is not due yet")
+
+	tournament, err = database.SelectTournament(db, ids[0])
+	assert.NoError(t, err, "func SelectTournament failed")
+	assert.Equal(t, int64(100), tournament.Deposit, "deposit should come from the template")
+	assert.Equal(t, 8, tournament.MaxPlayers, "player cap should come from the template")
+	assert.Equal(t, entity.TournamentIsAnnounced, tournament.Status, "registration should be open")
+	assert.Equal(t, 0, tournament.StartsAt.Minute(), "tournament should start at the full hour")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestSatelliteTicket(t *testing.T) {
+	var result entity.Result
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: 2, Deposit: 1, AddOnPrice: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, Format: entity.FormatRanked,
+		SatelliteFor: 2, SatelliteTickets: 1, TicketUnused: entity.TicketCash})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+
+	js, err := FinishTournament(db, testTournament.ID, &entity.TournamentResults{Ranking: []int{testUser2.ID, testUser.ID}})
+	assert.NoError(t, err, "func FinishTournament failed")
+	assert.NoError(t, json.Unmarshal(js, &result), "result output should be JSON")
+	ticket := result.Standings[0].Ticket
+	assert.NotEmpty(t, ticket, "satellite winner should get a ticket")
+
+	_, err = JoinTournamentWithTicket(db, testUser.ID, 2, ticket)
+	assert.Error(t, err, "ticket should be valid only for its holder")
+	_, err = JoinTournamentWithTicket(db, testUser2.ID, 2, ticket)
+	assert.NoError(t, err, "func JoinTournamentWithTicket failed")
+	_, err = JoinTournamentWithTicket(db, testUser2.ID, 2, ticket)
+	assert.Error(t, err, "ticket should be used only once")
+
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser.Points, points1, "the rest of the prize should be paid to the next player")
+	assert.Equal(t, testUser2.Points-100, points2, "entry with a ticket should not be charged")
+
+	_, err = BuyAddOn(db, testUser2.ID, 2)
+	assert.NoError(t, err, "func BuyAddOn failed")
+	_, err = LeaveTournament(db, testUser2.ID, 2)
+	assert.NoError(t, err, "func LeaveTournament failed")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	assert.Equal(t, testUser2.Points-100, points2, "add-on bought on top of a ticket entry should be refunded")
+	_, err = JoinTournamentWithTicket(db, testUser2.ID, 2, ticket)
+	assert.NoError(t, err, "ticket should be given back when the player leaves")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	_, _, err = CreateAPIKey(db, "server", "superuser")
+	assert.Error(t, err, "unknown scope should be rejected")
+	key, secret, err := CreateAPIKey(db, "server", entity.ScopeService)
+	assert.NoError(t, err, "func CreateAPIKey failed")
+	assert.NotEqual(t, secret, key.Hash, "the key should be stored hashed")
+	authenticated, err := AuthenticateAPIKey(db, secret)
+	assert.NoError(t, err, "func AuthenticateAPIKey failed")
+	assert.Equal(t, entity.ScopeService, authenticated.Scope, "scope mismatch")
+
+	rotated, secret2, err := RotateAPIKey(db, key.ID)
+	assert.NoError(t, err, "func RotateAPIKey failed")
+	_, err = AuthenticateAPIKey(db, secret)
+	assert.Error(t, err, "rotated key should be rejected")
+	_, err = AuthenticateAPIKey(db, secret2)
+	assert.NoError(t, err, "new key should be accepted")
+
+	err = RevokeAPIKey(db, rotated.ID)
+	assert.NoError(t, err, "func RevokeAPIKey failed")
+	_, err = AuthenticateAPIKey(db, secret2)
+	assert.Error(t, err, "revoked key should be rejected")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestRolePermissions(t *testing.T) {
+	assert.True(t, RoleAllows(entity.RoleSupport, entity.PermViewPlayers), "support should view balances")
+	assert.False(t, RoleAllows(entity.RoleSupport, entity.PermFundPlayers), "support should not fund accounts")
+	assert.True(t, RoleAllows(entity.RoleOperator, entity.PermManageTournaments), "operator should finish tournaments")
+	assert.False(t, RoleAllows(entity.RoleOperator, entity.PermFundPlayers), "operator should not change balances")
+	assert.False(t, RoleAllows(entity.RolePlayer, entity.PermManageTournaments), "player should not manage tournaments")
+	assert.True(t, RoleAllows(entity.RoleAdmin, entity.PermFundPlayers), "admin should fund accounts")
+	assert.False(t, RoleAllows(entity.RoleGameServer, entity.PermWithdraw), "game servers should not withdraw")
+	assert.Equal(t, entity.RoleGameServer, KeyRole(entity.ScopeService), "service keys should belong to game servers")
+	assert.Equal(t, "", KeyRole(entity.RolePlayer), "keys should not be issued to players")
+	assert.Equal(t, entity.RolePlayer, TokenRole(""), "tokens without a role should belong to players")
+}
+func TestValidatePayouts(t *testing.T) {
+	assert.NoError(t, validatePayouts([]float64{50, 30, 20}), "valid payouts should be accepted")
+	assert.Error(t, validatePayouts([]float64{math.NaN()}), "NaN payout should be refused")
+	assert.Error(t, validatePayouts([]float64{100, math.NaN()}), "NaN payout should be refused")
+	assert.Error(t, validatePayouts([]float64{math.Inf(1)}), "infinite payout should be refused")
+	assert.Error(t, validatePayouts([]float64{120, -20}), "negative payout should be refused")
+}
+
+func TestDistributePrizeTies(t *testing.T) {
+	standings := []entity.Standing{{PlayerID: 1, Place: 1}, {PlayerID: 2, Place: 1}, {PlayerID: 3, Place: 3}}
+	distributePrize(1000, []float64{50, 30, 20}, standings)
+	assert.Equal(t, int64(400), standings[0].Prize, "tied players should split first and second prize")
+	assert.Equal(t, int64(400), standings[1].Prize, "tied players should split first and second prize")
+	assert.Equal(t, int64(200), standings[2].Prize, "third place prize mismatch")
+}
+
+func TestDrawWinner(t *testing.T) {
+	seed, hash, err := newServerSeed()
+	assert.NoError(t, err, "func newServerSeed failed")
+	assert.Equal(t, hash, seedHash(seed), "published hash should match the seed")
+	players := []int{3, 5, 8}
+	winner := drawWinner(seed, "client", players)
+	assert.Contains(t, players, winner, "winner should be one of the players")
+	assert.Equal(t, winner, drawWinner(seed, "client", players), "draw should be reproducible")
+}
+
+func TestFinishTournamentPaysWinner(t *testing.T) {
+	var winner int
+	var points1, points2 int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = fundPlayer(db, testUser.ID, testUser.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = fundPlayer(db, testUser2.ID, testUser2.Points)
+	assert.NoError(t, err, "func fundPlayer failed")
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = JoinTournament(db, testUser.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = JoinTournament(db, testUser2.ID, testTournament.ID)
+	assert.NoError(t, err, "func JoinTournament failed")
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT winner FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&winner)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Contains(t, []int{testUser.ID, testUser2.ID}, winner, "winner should be a participant")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser.ID).Scan(&points1)
+	assert.NoError(t, err, "select player return error")
+	err = db.QueryRow("SELECT points FROM player WHERE id = $1 ", testUser2.ID).Scan(&points2)
+	assert.NoError(t, err, "select player return error")
+	if winner == testUser.ID {
+		assert.Equal(t, testUser.Points+100, points1, "prize should reach the winner balance")
+		assert.Equal(t, testUser2.Points-100, points2, "loser should only pay the deposit")
+	} else {
+		assert.Equal(t, testUser2.Points+100, points2, "prize should reach the winner balance")
+		assert.Equal(t, testUser.Points-100, points1, "loser should only pay the deposit")
+	}
+
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.Error(t, err, "finished tournament should not be finished twice")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestFinishEmptyTournament(t *testing.T) {
+	var status int
+	var houseBalance int64
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = AnnounceTournament(db, entity.AnnounceParams{ID: testTournament.ID, Deposit: 1, Guarantee: 10})
+	assert.NoError(t, err, "func AnnounceTournament failed")
+	_, err = FinishTournament(db, testTournament.ID, nil)
+	assert.NoError(t, err, "func FinishTournament failed")
+
+	err = db.QueryRow("SELECT status FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&status)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, entity.TournamentIsCancelled, status, "empty tournament should be cancelled")
+	err = db.QueryRow("SELECT balance FROM house_account WHERE id = 1").Scan(&houseBalance)
+	assert.NoError(t, err, "select house account return error")
+	assert.Equal(t, int64(0), houseBalance, "empty tournament should not fund the overlay")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+// playBracket reports every ready match won by the player with the lower id until the bracket is over.
+func playBracket(t *testing.T, matches []entity.Match) {
+	for played := true; played; {
+		played = false
+		for _, m := range matches {
+			if m.Status != entity.MatchReady {
+				continue
+			}
+			winner := m.Player1
+			if m.Player2 < winner {
+				winner = m.Player2
+			}
+			err := reportBracketMatch(matches, m.Number, entity.MatchResult{Winner: winner})
+			assert.NoError(t, err, "func reportBracketMatch failed")
+			played = true
+		}
+	}
+}
+
+func TestSingleEliminationBracket(t *testing.T) {
+	matches := buildBracket(1, []int{1, 2, 3, 4, 5}, false)
+	settleBracket(matches)
+	assert.Len(t, matches, 7, "bracket of 8 should have 7 matches")
+	playBracket(t, matches)
+
+	standings, err := bracketStandings(matches, false)
+	assert.NoError(t, err, "func bracketStandings failed")
+	assert.Len(t, standings, 5, "every player should be placed")
+	assert.Equal(t, entity.Standing{PlayerID: 1, Place: 1}, standings[0], "best seed should win")
+	assert.Equal(t, 2, standings[1].Place, "final loser should be second")
+	assert.Equal(t, 3, standings[2].Place, "semi final losers should share third place")
+	assert.Equal(t, 3, standings[3].Place, "semi final losers should share third place")
+	assert.Equal(t, 5, standings[4].Place, "first round loser should be fifth")
+}
+
+func TestDoubleEliminationBracket(t *testing.T) {
+	for _, n := range []int{2, 4, 6, 8} {
+		seeds := make([]int, n)
+		for i := range seeds {
+			seeds[i] = i + 1
+		}
+		matches := buildBracket(1, seeds, true)
+		settleBracket(matches)
+		playBracket(t, matches)
+
+		standings, err := bracketStandings(matches, true)
+		assert.NoError(t, err, "func bracketStandings failed")
+		assert.Len(t, standings, n, "every player should be placed")
+		assert.Equal(t, 1, standings[0].PlayerID, "best seed should win")
+		assert.Equal(t, 2, standings[1].PlayerID, "second seed should lose the grand final")
+	}
+}
+
+func TestRoundRobinPairings(t *testing.T) {
+	rounds := roundRobinPairings([]int{1, 2, 3, 4, 5})
+	assert.Len(t, rounds, 5, "five players should play five rounds")
+	met := map[[2]int]int{}
+	for _, pairs := range rounds {
+		for _, pair := range pairs {
+			met[pairKey(pair[0], pair[1])]++
+		}
+	}
+	assert.Len(t, met, 10, "every pair should meet")
+	for pair, count := range met {
+		assert.Equal(t, 1, count, "pair %v should meet once", pair)
+	}
+}
+
+func TestSwissPairingsAvoidRematch(t *testing.T) {
+	played := map[[2]int]bool{pairKey(1, 2): true, pairKey(3, 4): true}
+	pairs, bye := swissPairings([]int{1, 2, 3, 4, 5}, played, map[int]bool{5: true})
+	assert.Equal(t, 4, bye, "lowest player without a bye should get the bye")
+	assert.Equal(t, [][2]int{{1, 3}, {2, 5}}, pairs, "players should not meet again")
+}
+
+func TestLeagueStandingsTiebreak(t *testing.T) {
+	matches := []entity.Match{
+		{Player1: 1, Player2: 2, Winner: 2, Status: entity.MatchDone},
+		{Player1: 1, Player2: 3, Winner: 1, Status: entity.MatchDone},
+		{Player1: 2, Player2: 3, Winner: 3, Status: entity.MatchDone},
+	}
+	standings := leagueStandings(entity.FormatRoundRobin, []int{1, 2, 3}, matches)
+	for _, s := range standings {
+		assert.Equal(t, 1, s.Place, "circular results should share the place")
+	}
+	matches[2].Winner = 2
+	standings = leagueStandings(entity.FormatRoundRobin, []int{1, 2, 3}, matches)
+	assert.Equal(t, 2, standings[0].PlayerID, "player with two wins should lead")
+	assert.Equal(t, float64(2), standings[0].Points, "leader points mismatch")
+}
+
+func TestAuditChain(t *testing.T) {
+	records := make([]entity.AuditRecord, 0)
+	prev := ""
+	for i, target := range []string{"player:1", "tournament:2", "tournament:2"} {
+		r := entity.AuditRecord{
+			ID:        i + 1,
+			Actor:     "admin:1",
+			Action:    entity.AuditFundPlayer,
+			Target:    target,
+			After:     json.RawMessage(`{"balance":10}`),
+			CreatedAt: time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+			PrevHash:  prev,
+		}
+		r.Hash = auditHash(r)
+		prev = r.Hash
+		records = append(records, r)
+	}
+	assert.Equal(t, 0, verifyAuditChain(records), "untouched chain should be valid")
+
+	changed := append([]entity.AuditRecord(nil), records...)
+	changed[1].After = json.RawMessage(`{"balance":1000}`)
+	assert.Equal(t, 2, verifyAuditChain(changed), "changed record should break the chain")
+
+	removed := append([]entity.AuditRecord{records[0]}, records[2:]...)
+	assert.Equal(t, 3, verifyAuditChain(removed), "removed record should break the chain")
+}
+
+func TestAuditActor(t *testing.T) {
+	assert.Equal(t, "player:7", auditActor("player:7"), "short actor should be kept")
+	long := "player:" + strings.Repeat("é", 200)
+	bounded := auditActor(long)
+	assert.Equal(t, maxAuditActorLength, utf8.RuneCountInString(bounded), "long actor should fit the audit log")
+	assert.True(t, utf8.ValidString(bounded), "long actor should be cut at a character")
+	assert.True(t, strings.HasPrefix(bounded, "player:"), "long actor should keep its beginning")
+	assert.NotEqual(t, bounded, auditActor(long+"x"), "long actors should stay apart")
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// Raffle draws use a commit-reveal scheme. A random server seed is generated when the tournament is
+// announced and only its SHA-256 hash is published. At finish time the seed is combined with the sorted
+// participant list and an optional client seed, and HMAC-SHA256 keyed with the server seed is used as a
+// deterministic random generator to pick the winner. After the tournament is finished the seed is revealed,
+// so anyone can check it against the published hash and repeat the draw.
+
+// newServerSeed returns new random server seed and its hash.
+func newServerSeed() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	seed := hex.EncodeToString(b)
+	return seed, seedHash(seed), nil
+}
+
+// seedHash returns hex encoded SHA-256 hash of the seed.
+func seedHash(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// drawMessage canonical draw input: client seed and participant ids in ascending order.
+func drawMessage(clientSeed string, players []int) string {
+	ids := make([]string, len(players))
+	for i, id := range players {
+		ids[i] = strconv.Itoa(id)
+	}
+	return clientSeed + ":" + strings.Join(ids, ",")
+}
+
+// drawWinner picks the winner from players, which must be sorted. Every 64-bit number is taken
+// from HMAC-SHA256(serverSeed, message:counter), numbers which would bias the modulo are rejected.
+func drawWinner(serverSeed string, clientSeed string, players []int) int {
+	n := uint64(len(players))
+	limit := math.MaxUint64 - math.MaxUint64%n
+	message := drawMessage(clientSeed, players)
+	for counter := 0; ; counter++ {
+		mac := hmac.New(sha256.New, []byte(serverSeed))
+		mac.Write([]byte(message + ":" + strconv.Itoa(counter)))
+		sum := mac.Sum(nil)
+		for i := 0; i+8 <= len(sum); i += 8 {
+			v := binary.BigEndian.Uint64(sum[i : i+8])
+			if v < limit {
+				return players[v%n]
+			}
+		}
+	}
+}
+
+// drawPlayers returns sorted ids of the tournament participants.
+func drawPlayers(participants []entity.TournamentPlayer) []int {
+	players := make([]int, 0, len(participants))
+	for _, p := range participants {
+		players = append(players, p.PlayerID)
+	}
+	sort.Ints(players)
+	return players
+}
+
+// VerifyDraw reveals the server seed of a finished raffle tournament and repeats the draw,
+// so the published seed hash and the winner can be checked.
+func VerifyDraw(db *sql.DB, tournamentID int) ([]byte, error) {
+	tournament, err := database.SelectTournament(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Format != entity.FormatRaffle {
+		return nil, fmt.Errorf("tournament %d is not a raffle", tournamentID)
+	}
+	if tournament.Status != entity.TournamentIsFinished {
+		return nil, fmt.Errorf("the draw of tournament %d can be verified after it is finished", tournamentID)
+	}
+	draw, err := database.SelectTournamentDraw(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.DrawVerification{
+		TournamentID:   tournamentID,
+		ServerSeed:     draw.ServerSeed,
+		ServerSeedHash: draw.ServerSeedHash,
+		ClientSeed:     draw.ClientSeed,
+		Players:        draw.Players,
+		Winner:         tournament.Winner,
+	}
+	if len(draw.Players) > 0 {
+		res.DrawnWinner = drawWinner(draw.ServerSeed, draw.ClientSeed, draw.Players)
+	}
+	res.Verified = seedHash(draw.ServerSeed) == draw.ServerSeedHash && res.DrawnWinner == tournament.Winner
+	return json.Marshal(res)
+}
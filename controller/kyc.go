@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// KYCDepositThreshold points of tournament deposit above which players must be verified, zero disables the check
+var KYCDepositThreshold float64
+
+// kycDocumentKinds documents accepted for verification
+var kycDocumentKinds = map[string]bool{
+	entity.DocumentPassport:       true,
+	entity.DocumentIDCard:         true,
+	entity.DocumentDrivingLicence: true,
+	entity.DocumentProofOfAddress: true,
+}
+
+// SubmitKYCDocument stores reference to a verification document of the player. An unverified
+// or rejected player waits for a review afterwards.
+func SubmitKYCDocument(db *sql.DB, playerID int, kind string, reference string) ([]byte, error) {
+	if !kycDocumentKinds[kind] {
+		return nil, fmt.Errorf("unknown document kind %q", kind)
+	}
+	if reference == "" || len(reference) > 200 {
+		return nil, fmt.Errorf("document reference must have 1 to 200 characters")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player.Status == entity.AccountClosed {
+		return nil, fmt.Errorf("user %d account is closed", playerID)
+	}
+	err = database.InsertKYCDocument(tx, entity.KYCDocument{PlayerID: playerID, Kind: kind, Reference: reference})
+	if err != nil {
+		return nil, err
+	}
+	if player.KYCStatus == entity.KYCUnverified || player.KYCStatus == entity.KYCRejected {
+		err = database.SetPlayerKYCStatus(tx, playerID, entity.KYCPending, "", "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetKYC(db, playerID)
+}
+
+// ReviewKYC verifies or rejects the pending player, a rejection needs a note for the player.
+// A verified player may be rejected later, for example when a document turns out to be forged.
+func ReviewKYC(db *sql.DB, playerID int, decision string, note string, reviewer string) ([]byte, error) {
+	if decision != entity.KYCVerified && decision != entity.KYCRejected {
+		return nil, fmt.Errorf("unknown review decision %q", decision)
+	}
+	if decision == entity.KYCRejected && note == "" {
+		return nil, fmt.Errorf("rejection must have a note")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player.KYCStatus != entity.KYCPending && player.KYCStatus != entity.KYCVerified {
+		return nil, fmt.Errorf("user %d has no documents waiting for a review", playerID)
+	}
+	err = database.SetPlayerKYCStatus(tx, playerID, decision, note, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetKYC(db, playerID)
+}
+
+// GetKYC get the verification status of the player with the submitted documents.
+func GetKYC(db *sql.DB, playerID int) ([]byte, error) {
+	player, err := database.SelectPlayer(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := database.SelectKYCDocuments(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.KYCInfo{
+		PlayerID:   playerID,
+		Status:     player.KYCStatus,
+		Note:       player.KYCNote,
+		ReviewedAt: player.KYCReviewedAt,
+		Reviewer:   player.KYCReviewer,
+		Documents:  docs,
+	})
+}
+
+// Withdraw pays the points out of the player account. Only verified players may withdraw,
+// self-excluded and closed accounts may still take their money out, suspended accounts may not.
+func Withdraw(db *sql.DB, playerID int, points float64) ([]byte, error) {
+	amount := toAmount(points)
+	if amount <= 0 {
+		return nil, fmt.Errorf("withdrawal must be positive")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player.KYCStatus != entity.KYCVerified {
+		return nil, fmt.Errorf("user %d must be verified to withdraw", playerID)
+	}
+	if player.Status == entity.AccountSuspended {
+		return nil, fmt.Errorf("user %d account is suspended", playerID)
+	}
+	ok, err := database.DebitPlayer(tx, playerID, amount)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("user %d does not have enough points", playerID)
+	}
+	err = database.InsertPlayerWithdrawal(tx, playerID, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.WithdrawResults{PlayerID: playerID, Amount: points, Balance: toPoints(player.Points - amount)})
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// SubmitScore records a score of a player in a running leaderboard tournament
+// and returns the updated leaderboard.
+func SubmitScore(db *sql.DB, tournamentID int, playerID int, score float64) ([]byte, error) {
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return nil, fmt.Errorf("invalid score")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Format != entity.FormatLeaderboard {
+		return nil, fmt.Errorf("tournament %d is %s and does not accept scores", tournamentID, tournament.Format)
+	}
+	if tournament.Status != entity.TournamentIsRunning {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	_, err = database.SelectTournamentUser(tx, tournamentID, playerID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %d does not take part in the tournament", playerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertScore(tx, tournamentID, playerID, score)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetLeaderboard(db, tournamentID)
+}
+
+// GetLeaderboard returns the live leaderboard of the tournament, players with equal score share the place.
+func GetLeaderboard(db *sql.DB, tournamentID int) ([]byte, error) {
+	tournament, err := database.SelectTournament(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Format != entity.FormatLeaderboard {
+		return nil, fmt.Errorf("tournament %d is %s and has no leaderboard", tournamentID, tournament.Format)
+	}
+	entries, err := database.SelectLeaderboard(db, tournamentID, tournament.ScoreMode)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if i > 0 && entries[i].Score == entries[i-1].Score {
+			entries[i].Place = entries[i-1].Place
+		} else {
+			entries[i].Place = i + 1
+		}
+	}
+	return json.Marshal(entity.Leaderboard{
+		TournamentID: tournamentID,
+		Status:       entity.TournamentStatusName(tournament.Status),
+		ScoreMode:    tournament.ScoreMode,
+		Entries:      entries,
+	})
+}
+
+// leaderboardStandings snapshots the leaderboard as final standings. Participants
+// who never submitted a score share the place after the last scoring player.
+func leaderboardStandings(tx *sql.Tx, tournament entity.Tournament, participants []entity.TournamentPlayer) ([]entity.Standing, error) {
+	entries, err := database.SelectLeaderboard(tx, tournament.ID, tournament.ScoreMode)
+	if err != nil {
+		return nil, err
+	}
+	standings := make([]entity.Standing, 0, len(participants))
+	scored := make(map[int]bool, len(entries))
+	for _, e := range entries {
+		scored[e.PlayerID] = true
+		standings = append(standings, entity.Standing{PlayerID: e.PlayerID, Score: e.Score})
+	}
+	placeByScore(standings)
+	last := len(standings) + 1
+	for _, p := range participants {
+		if !scored[p.PlayerID] {
+			standings = append(standings, entity.Standing{PlayerID: p.PlayerID, Place: last})
+		}
+	}
+	return standings, nil
+}
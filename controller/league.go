@@ -0,0 +1,391 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// Round robin and Swiss tournaments score every match: a win is worth one point, a draw half a point.
+// Round robin matches are all generated at once with the circle method, Swiss rounds are paired one at
+// a time from the current standings, avoiding rematches where possible. A player left without opponent
+// in a Swiss round gets a bye, which counts as a win.
+
+// Points per match result
+const (
+	winPoints  = 1
+	drawPoints = 0.5
+)
+
+// isLeague reports whether the tournament format is played in rounds where every match scores points.
+func isLeague(format string) bool {
+	return format == entity.FormatRoundRobin || format == entity.FormatSwiss
+}
+
+// roundRobinPairings returns the pairings of every round, each player meets every other player once.
+func roundRobinPairings(players []int) [][][2]int {
+	ps := append([]int(nil), players...)
+	if len(ps)%2 == 1 {
+		ps = append(ps, 0)
+	}
+	n := len(ps)
+	rounds := make([][][2]int, 0, n-1)
+	for r := 0; r < n-1; r++ {
+		pairs := make([][2]int, 0, n/2)
+		for i := 0; i < n/2; i++ {
+			a, b := ps[i], ps[n-1-i]
+			if a != 0 && b != 0 {
+				pairs = append(pairs, [2]int{a, b})
+			}
+		}
+		rounds = append(rounds, pairs)
+		// keep the first player in place and rotate the others
+		last := ps[n-1]
+		copy(ps[2:], ps[1:n-1])
+		ps[1] = last
+	}
+	return rounds
+}
+
+// swissPairings pairs players, ordered by standings, with the closest player they have not met yet.
+// With an odd number of players the lowest placed player without a bye yet gets the bye, returned separately.
+func swissPairings(order []int, played map[[2]int]bool, hadBye map[int]bool) ([][2]int, int) {
+	players := append([]int(nil), order...)
+	bye := 0
+	if len(players)%2 == 1 {
+		i := len(players) - 1
+		for i > 0 && hadBye[players[i]] {
+			i--
+		}
+		bye = players[i]
+		players = append(players[:i], players[i+1:]...)
+	}
+	pairs, ok := pairWithoutRematch(players, played)
+	if !ok {
+		pairs = pairs[:0]
+		for i := 0; i+1 < len(players); i += 2 {
+			pairs = append(pairs, [2]int{players[i], players[i+1]})
+		}
+	}
+	return pairs, bye
+}
+
+// pairWithoutRematch pairs the first player with the next one he has not played and recurses on the rest.
+func pairWithoutRematch(players []int, played map[[2]int]bool) ([][2]int, bool) {
+	if len(players) == 0 {
+		return [][2]int{}, true
+	}
+	first := players[0]
+	for j := 1; j < len(players); j++ {
+		if played[pairKey(first, players[j])] {
+			continue
+		}
+		rest := make([]int, 0, len(players)-2)
+		rest = append(rest, players[1:j]...)
+		rest = append(rest, players[j+1:]...)
+		pairs, ok := pairWithoutRematch(rest, played)
+		if ok {
+			return append([][2]int{{first, players[j]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// pairKey returns the same key for both orders of the players.
+func pairKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// leagueStats collects points and results of every player from the finished matches.
+func leagueStats(players []int, matches []entity.Match) map[int]*entity.LeagueStanding {
+	stats := make(map[int]*entity.LeagueStanding, len(players))
+	for _, id := range players {
+		stats[id] = &entity.LeagueStanding{PlayerID: id}
+	}
+	for _, m := range matches {
+		if m.Status != entity.MatchDone {
+			continue
+		}
+		p1, p2 := stats[m.Player1], stats[m.Player2]
+		if p1 == nil {
+			continue
+		}
+		if p2 == nil {
+			// bye
+			p1.Points += winPoints
+			continue
+		}
+		p1.Played++
+		p2.Played++
+		switch m.Winner {
+		case m.Player1:
+			p1.Points += winPoints
+			p1.Wins++
+			p2.Losses++
+		case m.Player2:
+			p2.Points += winPoints
+			p2.Wins++
+			p1.Losses++
+		default:
+			p1.Points += drawPoints
+			p2.Points += drawPoints
+			p1.Draws++
+			p2.Draws++
+		}
+	}
+	return stats
+}
+
+// leagueStandings orders players by points, then by Buchholz score (sum of the opponents' points) for Swiss
+// or by points scored against the other tied players for round robin. Players equal on both share the place.
+func leagueStandings(format string, players []int, matches []entity.Match) []entity.LeagueStanding {
+	stats := leagueStats(players, matches)
+	for _, m := range matches {
+		if m.Status != entity.MatchDone || stats[m.Player1] == nil || stats[m.Player2] == nil {
+			continue
+		}
+		p1, p2 := stats[m.Player1], stats[m.Player2]
+		if format == entity.FormatSwiss {
+			p1.Tiebreak += p2.Points
+			p2.Tiebreak += p1.Points
+			continue
+		}
+		if p1.Points != p2.Points {
+			continue
+		}
+		switch m.Winner {
+		case m.Player1:
+			p1.Tiebreak += winPoints
+		case m.Player2:
+			p2.Tiebreak += winPoints
+		default:
+			p1.Tiebreak += drawPoints
+			p2.Tiebreak += drawPoints
+		}
+	}
+	standings := make([]entity.LeagueStanding, 0, len(stats))
+	for _, s := range stats {
+		standings = append(standings, *s)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Tiebreak != b.Tiebreak {
+			return a.Tiebreak > b.Tiebreak
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Points == standings[i-1].Points && standings[i].Tiebreak == standings[i-1].Tiebreak {
+			standings[i].Place = standings[i-1].Place
+		} else {
+			standings[i].Place = i + 1
+		}
+	}
+	return standings
+}
+
+// swissRounds returns the number of Swiss rounds, by default enough rounds to find a single winner.
+func swissRounds(tournament entity.Tournament, players int) int {
+	if tournament.SwissRounds > 0 {
+		return tournament.SwissRounds
+	}
+	rounds := 0
+	for size := 1; size < players; size *= 2 {
+		rounds++
+	}
+	return rounds
+}
+
+// leagueComplete checks that every match is played and, for Swiss, every round is paired.
+func leagueComplete(tournament entity.Tournament, players int, matches []entity.Match) error {
+	rounds := 0
+	for _, m := range matches {
+		if m.Status != entity.MatchDone {
+			return fmt.Errorf("match %d is not played yet", m.Number)
+		}
+		if m.Round > rounds {
+			rounds = m.Round
+		}
+	}
+	if len(matches) == 0 || tournament.Format == entity.FormatSwiss && rounds < swissRounds(tournament, players) {
+		return fmt.Errorf("not all rounds of tournament %d are played", tournament.ID)
+	}
+	return nil
+}
+
+// participantIDs returns participant ids in join order.
+func participantIDs(participants []entity.TournamentPlayer) []int {
+	ids := make([]int, 0, len(participants))
+	for _, p := range participants {
+		ids = append(ids, p.PlayerID)
+	}
+	return ids
+}
+
+// GenerateRound generates matches of a running round robin or Swiss tournament: all rounds of a round robin
+// at once, the next Swiss round after all matches of the previous one are played.
+func GenerateRound(db *sql.DB, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isLeague(tournament.Format) {
+		return nil, fmt.Errorf("tournament %d is not a round robin or Swiss tournament", tournamentID)
+	}
+	if tournament.Status != entity.TournamentIsRunning {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	participants, err := database.SelectTournamentUsers(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(participants) < 2 {
+		return nil, fmt.Errorf("tournament needs at least two players")
+	}
+	players := participantIDs(participants)
+	matches, err := database.SelectMatches(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rounds [][][2]int
+	byes := map[int]int{}
+	round := 1
+	if tournament.Format == entity.FormatRoundRobin {
+		if len(matches) > 0 {
+			return nil, fmt.Errorf("matches of tournament %d are already generated", tournamentID)
+		}
+		rounds = roundRobinPairings(players)
+	} else {
+		played := map[[2]int]bool{}
+		hadBye := map[int]bool{}
+		for _, m := range matches {
+			if m.Status != entity.MatchDone {
+				return nil, fmt.Errorf("round %d is not finished yet", m.Round)
+			}
+			if m.Player2 == 0 {
+				hadBye[m.Player1] = true
+			}
+			played[pairKey(m.Player1, m.Player2)] = true
+			if m.Round >= round {
+				round = m.Round + 1
+			}
+		}
+		if round > swissRounds(tournament, len(players)) {
+			return nil, fmt.Errorf("all rounds of tournament %d are generated", tournamentID)
+		}
+		order := make([]int, 0, len(players))
+		for _, s := range leagueStandings(tournament.Format, players, matches) {
+			order = append(order, s.PlayerID)
+		}
+		pairs, bye := swissPairings(order, played, hadBye)
+		rounds = [][][2]int{pairs}
+		if bye != 0 {
+			byes[round] = bye
+		}
+	}
+
+	bracket := entity.BracketRoundRobin
+	if tournament.Format == entity.FormatSwiss {
+		bracket = entity.BracketSwiss
+	}
+	number := len(matches)
+	for i, pairs := range rounds {
+		r := round + i
+		for p, pair := range pairs {
+			number++
+			m := entity.Match{TournamentID: tournamentID, Number: number, Bracket: bracket, Round: r, Position: p + 1,
+				Player1: pair[0], Player2: pair[1], Filled: 2, Status: entity.MatchReady}
+			matches = append(matches, m)
+			err = database.InsertMatch(tx, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if bye := byes[r]; bye != 0 {
+			number++
+			m := entity.Match{TournamentID: tournamentID, Number: number, Bracket: bracket, Round: r, Position: len(pairs) + 1,
+				Player1: bye, Winner: bye, Filled: 2, Status: entity.MatchDone}
+			matches = append(matches, m)
+			err = database.InsertMatch(tx, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return matchesJSON(matches)
+}
+
+// reportLeagueMatch records the result of a ready match, winner 0 is a draw.
+func reportLeagueMatch(matches []entity.Match, number int, result entity.MatchResult) error {
+	if number < 1 || number > len(matches) {
+		return fmt.Errorf("match %d does not exist", number)
+	}
+	m := &matches[number-1]
+	if m.Status != entity.MatchReady {
+		return fmt.Errorf("match %d is %s", number, m.Status)
+	}
+	if result.Winner != 0 && result.Winner != m.Player1 && result.Winner != m.Player2 {
+		return fmt.Errorf("user %d does not play match %d", result.Winner, number)
+	}
+	m.Score1, m.Score2 = result.Score1, result.Score2
+	m.Winner = result.Winner
+	m.Status = entity.MatchDone
+	return nil
+}
+
+// GetStandings get current standings of a round robin or Swiss tournament.
+func GetStandings(db *sql.DB, tournamentID int) ([]byte, error) {
+	tournament, err := database.SelectTournament(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isLeague(tournament.Format) {
+		return nil, fmt.Errorf("tournament %d is not a round robin or Swiss tournament", tournamentID)
+	}
+	participants, err := database.SelectTournamentUsers(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := database.SelectMatches(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	standings := leagueStandings(tournament.Format, participantIDs(participants), matches)
+	return json.Marshal(entity.LeagueStandings{Standings: standings})
+}
+
+// leagueFinalStandings returns final standings of a completed round robin or Swiss tournament.
+func leagueFinalStandings(tournament entity.Tournament, participants []entity.TournamentPlayer, matches []entity.Match) ([]entity.Standing, error) {
+	players := participantIDs(participants)
+	err := leagueComplete(tournament, len(players), matches)
+	if err != nil {
+		return nil, err
+	}
+	league := leagueStandings(tournament.Format, players, matches)
+	standings := make([]entity.Standing, 0, len(league))
+	for _, s := range league {
+		standings = append(standings, entity.Standing{PlayerID: s.PlayerID, Place: s.Place, Score: s.Points})
+	}
+	return standings, nil
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// LimitCoolingOff delay before a raised or removed responsible-gaming limit takes effect
+var LimitCoolingOff = 24 * time.Hour
+
+// limitPeriods rolling windows of the limit periods
+var limitPeriods = map[string]time.Duration{
+	entity.LimitDaily:   24 * time.Hour,
+	entity.LimitWeekly:  7 * 24 * time.Hour,
+	entity.LimitMonthly: 30 * 24 * time.Hour,
+}
+
+// limitError refusal of a money operation by responsible-gaming rules
+type limitError string
+
+func (e limitError) Error() string {
+	return string(e)
+}
+
+// playerLimits returns the limits in effect, pending changes which have waited long enough are applied first.
+func playerLimits(db database.Queryer, playerID int, now time.Time) ([]entity.PlayerLimit, error) {
+	limits, err := database.SelectPlayerLimits(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	effective := make([]entity.PlayerLimit, 0, len(limits))
+	for _, l := range limits {
+		if l.PendingFrom != nil && !now.Before(*l.PendingFrom) {
+			if l.PendingAmount == 0 {
+				err = database.DeletePlayerLimit(db, playerID, l.Kind, l.Period)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			l.Amount, l.PendingAmount, l.PendingFrom = l.PendingAmount, 0, nil
+			err = database.SavePlayerLimit(db, l)
+			if err != nil {
+				return nil, err
+			}
+		}
+		effective = append(effective, l)
+	}
+	return effective, nil
+}
+
+// limitUsage sums the money of the limit kind the player has used since the time.
+func limitUsage(db database.Queryer, playerID int, kind string, since time.Time) (int64, error) {
+	if kind == entity.LimitDeposit {
+		return database.SumPlayerDeposits(db, playerID, since)
+	}
+	return database.SumPlayerSpend(db, playerID, since)
+}
+
+// checkLimits refuses the amount of the limit kind when the player account is not active or the amount
+// would exceed one of the player limits. Zero amount checks only the account status.
+func checkLimits(tx *sql.Tx, player entity.Player, kind string, amount int64, now time.Time) error {
+	err := checkAccount(player, now)
+	if err != nil {
+		return err
+	}
+	if amount == 0 {
+		return nil
+	}
+	limits, err := playerLimits(tx, player.ID, now)
+	if err != nil {
+		return err
+	}
+	for _, l := range limits {
+		if l.Kind != kind {
+			continue
+		}
+		used, err := limitUsage(tx, player.ID, kind, now.Add(-limitPeriods[l.Period]))
+		if err != nil {
+			return err
+		}
+		if used+amount > l.Amount {
+			return limitError(fmt.Sprintf("user %d would exceed the %s %s limit of %v", player.ID, l.Period, kind, toPoints(l.Amount)))
+		}
+	}
+	return nil
+}
+
+// checkSpend locks the player and checks the spend of the amount in the tournament. Tournaments
+// with a deposit above KYCDepositThreshold are open only to verified players.
+func checkSpend(tx *sql.Tx, playerID int, tournament entity.Tournament, amount int64, now time.Time) error {
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return err
+	}
+	err = checkLimits(tx, player, entity.LimitSpend, amount, now)
+	if err != nil {
+		return err
+	}
+	if KYCDepositThreshold > 0 && tournament.Deposit > toAmount(KYCDepositThreshold) && player.KYCStatus != entity.KYCVerified {
+		return limitError(fmt.Sprintf("user %d must be verified to join tournament %d", playerID, tournament.ID))
+	}
+	return nil
+}
+
+// SetPlayerLimit sets the responsible-gaming limit of the player, zero points remove it. A lower limit
+// takes effect at once, a higher or removed limit only after the cooling-off delay.
+func SetPlayerLimit(db *sql.DB, playerID int, kind string, period string, points float64) ([]byte, error) {
+	if kind != entity.LimitDeposit && kind != entity.LimitSpend {
+		return nil, fmt.Errorf("unknown limit kind %q", kind)
+	}
+	if _, ok := limitPeriods[period]; !ok {
+		return nil, fmt.Errorf("unknown limit period %q", period)
+	}
+	if points < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+	amount := toAmount(points)
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := playerLimits(tx, playerID, now)
+	if err != nil {
+		return nil, err
+	}
+	var current *entity.PlayerLimit
+	for i := range limits {
+		if limits[i].Kind == kind && limits[i].Period == period {
+			current = &limits[i]
+		}
+	}
+	switch {
+	case current == nil && amount == 0:
+	case current == nil:
+		err = database.SavePlayerLimit(tx, entity.PlayerLimit{PlayerID: playerID, Kind: kind, Period: period, Amount: amount})
+	case amount != 0 && amount <= current.Amount:
+		current.Amount, current.PendingAmount, current.PendingFrom = amount, 0, nil
+		err = database.SavePlayerLimit(tx, *current)
+	default:
+		from := now.Add(LimitCoolingOff)
+		current.PendingAmount, current.PendingFrom = amount, &from
+		err = database.SavePlayerLimit(tx, *current)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetPlayerLimits(db, playerID)
+}
+
+// SelfExclude excludes the player from funding and tournaments for the days. A running
+// self-exclusion can only be extended, suspended and closed accounts can't be self-excluded.
+func SelfExclude(db *sql.DB, playerID int, days int) ([]byte, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("self-exclusion must last at least one day")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	until := now.AddDate(0, 0, days)
+	switch accountStatus(player, now) {
+	case entity.AccountActive:
+	case entity.AccountSelfExcluded:
+		if player.ExcludedUntil == nil || until.Before(*player.ExcludedUntil) {
+			return nil, fmt.Errorf("user %d is already self-excluded for longer", playerID)
+		}
+	default:
+		return nil, fmt.Errorf("user %d account is %s", playerID, player.Status)
+	}
+	err = database.SetPlayerStatus(tx, entity.AccountStatusChange{PlayerID: playerID, Status: entity.AccountSelfExcluded,
+		Reason: fmt.Sprintf("self-exclusion for %d days", days), ExcludedUntil: &until, Actor: entity.RolePlayer})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetPlayerLimits(db, playerID)
+}
+
+// GetPlayerLimits get the limits of the player with the amounts used in their periods.
+// The player is locked while pending limits that came into effect are applied, like in checkLimits.
+func GetPlayerLimits(db *sql.DB, playerID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	player, err := database.SelectPlayerForUpdate(tx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	limits, err := playerLimits(tx, playerID, now)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.PlayerLimits{PlayerID: playerID, Limits: make([]entity.LimitInfo, 0, len(limits))}
+	if accountStatus(player, now) == entity.AccountSelfExcluded {
+		res.ExcludedUntil = player.ExcludedUntil
+	}
+	for _, l := range limits {
+		used, err := limitUsage(tx, playerID, l.Kind, now.Add(-limitPeriods[l.Period]))
+		if err != nil {
+			return nil, err
+		}
+		info := entity.LimitInfo{Kind: l.Kind, Period: l.Period, Amount: toPoints(l.Amount), Used: toPoints(used), PendingFrom: l.PendingFrom}
+		if l.PendingFrom != nil {
+			pending := toPoints(l.PendingAmount)
+			info.PendingAmount = &pending
+		}
+		res.Limits = append(res.Limits, info)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
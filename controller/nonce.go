@@ -0,0 +1,14 @@
+package controller
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mishelini/database"
+)
+
+// UseRequestNonce records the nonce of a signed request. It returns false when the integration
+// has already sent a request with the nonce, the request is then a replay.
+func UseRequestNonce(db *sql.DB, integration string, nonce string, expiresAt time.Time) (bool, error) {
+	return database.InsertRequestNonce(db, integration, nonce, expiresAt)
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// ReEnterTournament lets a player who already joined pay the deposit again, as long as the
+// re-entry limit of the tournament is not reached and late registration is still open.
+func ReEnterTournament(db *sql.DB, playerID int, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.MaxReEntries == 0 {
+		return nil, fmt.Errorf("tournament %d does not allow re-entries", tournamentID)
+	}
+	if !reEntryIsOpen(tournament, time.Now()) {
+		return nil, fmt.Errorf("re-entry to tournament %d is closed", tournamentID)
+	}
+	entry, err := tournamentEntry(tx, tournamentID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Entries-1 >= tournament.MaxReEntries {
+		return nil, fmt.Errorf("user %d has used all %d re-entries", playerID, tournament.MaxReEntries)
+	}
+	return purchase(tx, tournament, playerID, entity.PurchaseReEntry, tournament.Deposit, rake(tournament))
+}
+
+// BuyAddOn lets a player of the tournament buy an add-on during the add-on window.
+// The add-on is raked by the rake percent of the tournament, the fixed rake is charged only on entries.
+func BuyAddOn(db *sql.DB, playerID int, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.AddOnPrice == 0 {
+		return nil, fmt.Errorf("tournament %d does not sell add-ons", tournamentID)
+	}
+	if !addOnIsOpen(tournament, time.Now()) {
+		return nil, fmt.Errorf("add-ons of tournament %d are closed", tournamentID)
+	}
+	entry, err := tournamentEntry(tx, tournamentID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.MaxAddOns > 0 && entry.AddOns >= tournament.MaxAddOns {
+		return nil, fmt.Errorf("user %d has bought all %d add-ons", playerID, tournament.MaxAddOns)
+	}
+	houseShare := int64(math.Round(float64(tournament.AddOnPrice) * tournament.RakePercent / 100))
+	return purchase(tx, tournament, playerID, entity.PurchaseAddOn, tournament.AddOnPrice, houseShare)
+}
+
+// GetPurchases returns the purchase ledger of the tournament.
+func GetPurchases(db *sql.DB, tournamentID int) ([]byte, error) {
+	purchases, err := database.SelectPurchases(db, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.Purchases{TournamentID: tournamentID, Purchases: make([]entity.PurchaseInfo, 0, len(purchases))}
+	for _, p := range purchases {
+		res.Purchases = append(res.Purchases, entity.PurchaseInfo{
+			PlayerID:  p.PlayerID,
+			Kind:      p.Kind,
+			Amount:    toPoints(p.Amount),
+			Rake:      toPoints(p.Rake),
+			CreatedAt: p.CreatedAt,
+		})
+	}
+	return json.Marshal(res)
+}
+
+// reEntryIsOpen reports whether players may still re-enter: the tournament is open or running
+// and its registration close time, if any, has not passed.
+func reEntryIsOpen(tournament entity.Tournament, now time.Time) bool {
+	if tournament.Status != entity.TournamentIsAnnounced && tournament.Status != entity.TournamentIsRunning {
+		return false
+	}
+	return tournament.RegistrationClosesAt == nil || now.Before(*tournament.RegistrationClosesAt)
+}
+
+// addOnIsOpen reports whether the tournament is not over yet and its add-on window has not closed.
+func addOnIsOpen(tournament entity.Tournament, now time.Time) bool {
+	switch tournament.Status {
+	case entity.TournamentIsAnnounced, entity.TournamentIsRegistrationClosed, entity.TournamentIsRunning:
+	default:
+		return false
+	}
+	return tournament.AddOnClosesAt == nil || now.Before(*tournament.AddOnClosesAt)
+}
+
+// tournamentEntry returns the entry of a player who takes part in the tournament.
+func tournamentEntry(tx *sql.Tx, tournamentID int, playerID int) (entity.TournamentPlayer, error) {
+	entry, err := database.SelectTournamentUser(tx, tournamentID, playerID)
+	if err == sql.ErrNoRows {
+		return entry, fmt.Errorf("user %d does not take part in the tournament", playerID)
+	}
+	return entry, err
+}
+
+// purchase charges the player, adds the amount without the rake to the prize pool,
+// counts the purchase on the player entry and records it in the ledgers.
+func purchase(tx *sql.Tx, tournament entity.Tournament, playerID int, kind string, amount int64, houseShare int64) ([]byte, error) {
+	err := checkSpend(tx, playerID, tournament, amount, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ok, err := database.DebitPlayer(tx, playerID, amount)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("user %d does not have enough points", playerID)
+	}
+	err = database.AddTournamentPrize(tx, tournament.ID, amount-houseShare)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := database.AddTournamentPurchase(tx, tournament.ID, playerID, kind, amount, houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertPurchase(tx, entity.Purchase{TournamentID: tournament.ID, PlayerID: playerID,
+		Kind: kind, Amount: amount, Rake: houseShare})
+	if err != nil {
+		return nil, err
+	}
+	err = collectRake(tx, tournament.ID, playerID, houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.PurchaseResults{
+		PlayerID:     playerID,
+		TournamentID: tournament.ID,
+		Kind:         kind,
+		Amount:       toPoints(amount),
+		Entries:      entry.Entries,
+		AddOns:       entry.AddOns,
+		Prize:        toPoints(tournament.Prize + amount - houseShare),
+	})
+}
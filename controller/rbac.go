@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// rolePermissions permissions granted to every role. Players get their permissions
+// only for their own account, the handler checks the player id.
+var rolePermissions = map[string][]string{
+	entity.RoleAdmin: {
+		entity.PermFundPlayers, entity.PermSetLimits, entity.PermWithdraw, entity.PermViewPlayers, entity.PermManagePlayers, entity.PermManageTournaments,
+		entity.PermPlay, entity.PermReportResults, entity.PermViewTournaments, entity.PermViewHouse, entity.PermViewAudit,
+		entity.PermViewMetrics,
+	},
+	entity.RoleOperator: {
+		entity.PermManageTournaments, entity.PermReportResults, entity.PermViewTournaments, entity.PermViewMetrics,
+	},
+	entity.RoleSupport: {
+		entity.PermViewPlayers, entity.PermViewTournaments,
+	},
+	entity.RoleGameServer: {
+		entity.PermPlay, entity.PermReportResults, entity.PermViewPlayers, entity.PermViewTournaments,
+	},
+	entity.RolePlayer: {
+		entity.PermPlay, entity.PermSetLimits, entity.PermWithdraw, entity.PermViewPlayers, entity.PermViewTournaments,
+	},
+}
+
+// keyRoles roles of the API key scopes, the first key scopes stand for their closest roles
+var keyRoles = map[string]string{
+	entity.ScopeAdmin:     entity.RoleAdmin,
+	entity.ScopeService:   entity.RoleGameServer,
+	entity.ScopeReadOnly:  entity.RoleSupport,
+	entity.RoleOperator:   entity.RoleOperator,
+	entity.RoleSupport:    entity.RoleSupport,
+	entity.RoleGameServer: entity.RoleGameServer,
+}
+
+// RoleAllows reports whether the role has the permission.
+func RoleAllows(role string, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyRole returns the role of an API key scope or an empty string for unknown scopes.
+func KeyRole(scope string) string {
+	return keyRoles[scope]
+}
+
+// TokenRole returns the role of a token, tokens without a role belong to players.
+func TokenRole(role string) string {
+	if role == "" {
+		return entity.RolePlayer
+	}
+	if _, ok := rolePermissions[role]; ok {
+		return role
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// validatePayouts checks payout percentages per place, they have to be positive and sum up to 100.
+func validatePayouts(payouts []float64) error {
+	total := 0.0
+	for _, p := range payouts {
+		if math.IsNaN(p) || math.IsInf(p, 0) || p <= 0 {
+			return fmt.Errorf("payout percentages must be positive")
+		}
+		total += p
+	}
+	if len(payouts) > 0 && math.Abs(total-100) > 1e-9 {
+		return fmt.Errorf("payout percentages must sum up to 100")
+	}
+	return nil
+}
+
+// rankResults validates posted results against the tournament participants and turns them into standings.
+// Every ranked player has to be a participant and may appear only once.
+func rankResults(results entity.TournamentResults, participants []entity.TournamentPlayer) ([]entity.Standing, error) {
+	if (len(results.Ranking) == 0) == (len(results.Scores) == 0) {
+		return nil, fmt.Errorf("results must contain either a ranking or scores")
+	}
+	joined := make(map[int]bool, len(participants))
+	for _, p := range participants {
+		joined[p.PlayerID] = true
+	}
+	standings := make([]entity.Standing, 0, len(results.Ranking)+len(results.Scores))
+	if len(results.Ranking) > 0 {
+		seen := make(map[int]bool, len(results.Ranking))
+		for i, playerID := range results.Ranking {
+			if seen[playerID] {
+				return nil, fmt.Errorf("user %d is ranked more than once", playerID)
+			}
+			seen[playerID] = true
+			standings = append(standings, entity.Standing{PlayerID: playerID, Place: i + 1})
+		}
+	} else {
+		for playerID, score := range results.Scores {
+			standings = append(standings, entity.Standing{PlayerID: playerID, Score: score})
+		}
+		placeByScore(standings)
+	}
+	for _, s := range standings {
+		if !joined[s.PlayerID] {
+			return nil, fmt.Errorf("user %d does not take part in the tournament", s.PlayerID)
+		}
+	}
+	return standings, nil
+}
+
+// placeByScore sorts standings by score, best first, and gives players with equal score the same place.
+func placeByScore(standings []entity.Standing) {
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].PlayerID < standings[j].PlayerID
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Place = standings[i-1].Place
+		} else {
+			standings[i].Place = i + 1
+		}
+	}
+}
+
+// distributePrize splits the prize between the standings, which must be ordered by place, according
+// to the payout percentages. When fewer players are ranked than there are paid places the percentages
+// of the paid places are scaled up. Players sharing a place split the prizes of the places they occupy.
+func distributePrize(prize int64, payouts []float64, standings []entity.Standing) {
+	paid := len(payouts)
+	if paid > len(standings) {
+		paid = len(standings)
+	}
+	if paid == 0 || prize <= 0 {
+		return
+	}
+	total := 0.0
+	for _, p := range payouts[:paid] {
+		total += p
+	}
+	amounts := make([]int64, len(standings))
+	rest := prize
+	for i := 0; i < paid; i++ {
+		amounts[i] = int64(float64(prize) * payouts[i] / total)
+		rest -= amounts[i]
+	}
+	amounts[0] += rest
+
+	for start := 0; start < len(standings); {
+		end := start + 1
+		for end < len(standings) && standings[end].Place == standings[start].Place {
+			end++
+		}
+		var pool int64
+		for i := start; i < end; i++ {
+			pool += amounts[i]
+		}
+		share := pool / int64(end-start)
+		for i := start; i < end; i++ {
+			standings[i].Prize = share
+		}
+		standings[start].Prize += pool - share*int64(end-start)
+		start = end
+	}
+}
+
+// payStandings credits the prizes to the players and stores the final standings.
+func payStandings(tx *sql.Tx, tournamentID int, standings []entity.Standing) error {
+	for _, s := range standings {
+		if s.Prize > 0 {
+			err := database.AddPlayerPoints(tx, s.PlayerID, s.Prize)
+			if err != nil {
+				return err
+			}
+		}
+		err := database.InsertTournamentResult(tx, tournamentID, s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// standingResults converts standings to JSON output with current player balances.
+func standingResults(db database.Queryer, standings []entity.Standing) ([]entity.StandingResult, error) {
+	res := make([]entity.StandingResult, 0, len(standings))
+	for _, s := range standings {
+		player, err := database.SelectPlayer(db, s.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, entity.StandingResult{
+			PlayerID: s.PlayerID,
+			Place:    s.Place,
+			Prize:    toPoints(s.Prize),
+			Balance:  toPoints(player.Points),
+			Ticket:   s.Ticket,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// CreateTeam creates a named team with the captain as its first member.
+func CreateTeam(db *sql.DB, name string, captainID int) ([]byte, error) {
+	if name == "" {
+		return nil, fmt.Errorf("team name must not be empty")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	teamID, err := database.InsertTeam(tx, name, captainID)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertTeamMember(tx, entity.TeamMember{TeamID: teamID, PlayerID: captainID, Share: 1, Accepted: true})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetTeam(db, teamID)
+}
+
+// AddTeamMember lets the captain invite the player to the team with his share of the team prizes.
+// The player joins the roster once he accepts the invite.
+func AddTeamMember(db *sql.DB, teamID int, captainID int, playerID int, share float64) ([]byte, error) {
+	if math.IsNaN(share) || math.IsInf(share, 0) || share <= 0 {
+		return nil, fmt.Errorf("prize share must be a positive number")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	team, err := database.SelectTeamForUpdate(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team.CaptainID != captainID {
+		return nil, fmt.Errorf("only the captain of team %d can add members", teamID)
+	}
+	err = checkRosterOpen(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertTeamMember(tx, entity.TeamMember{TeamID: teamID, PlayerID: playerID, Share: share})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetTeam(db, teamID)
+}
+
+// AcceptTeamInvite puts the invited player on the roster of the team.
+func AcceptTeamInvite(db *sql.DB, teamID int, playerID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = database.SelectTeamForUpdate(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkRosterOpen(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := database.AcceptTeamMember(tx, teamID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("player %d has no pending invite to team %d", playerID, teamID)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return GetTeam(db, teamID)
+}
+
+// checkRosterOpen refuses roster changes while the team is entered in a tournament that is not over yet.
+func checkRosterOpen(tx *sql.Tx, teamID int) error {
+	count, err := database.CountOpenTeamEntries(tx, teamID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("team %d is entered in a tournament, its roster can't change", teamID)
+	}
+	return nil
+}
+
+// GetTeam get team with its members from database layer.
+func GetTeam(db *sql.DB, teamID int) ([]byte, error) {
+	team, err := database.SelectTeam(db, teamID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := database.SelectTeamMembers(db, teamID)
+	if err != nil {
+		return nil, err
+	}
+	invites, err := database.SelectTeamInvites(db, teamID)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.TeamInfo{ID: team.ID, Name: team.Name, CaptainID: team.CaptainID, Members: make([]entity.TeamMemberInfo, 0, len(members))}
+	for _, m := range members {
+		res.Members = append(res.Members, entity.TeamMemberInfo{PlayerID: m.PlayerID, Share: m.Share})
+	}
+	for _, m := range invites {
+		res.Invites = append(res.Invites, entity.TeamMemberInfo{PlayerID: m.PlayerID, Share: m.Share})
+	}
+	return json.Marshal(res)
+}
+
+// JoinTeamTournament lets the captain enter the team into a team tournament. Depending on the tournament
+// the deposit is paid by the captain or split equally across the members, the rake is split the same way.
+func JoinTeamTournament(db *sql.DB, teamID int, captainID int, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if !tournament.TeamMode {
+		return nil, fmt.Errorf("tournament %d is not a team tournament", tournamentID)
+	}
+	if !registrationIsOpen(tournament, time.Now()) {
+		return nil, fmt.Errorf("tournment is closed")
+	}
+	if tournament.MaxPlayers > 0 {
+		count, err := database.CountTournamentTeams(tx, tournamentID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= tournament.MaxPlayers {
+			return nil, fmt.Errorf("tournament %d is full", tournamentID)
+		}
+	}
+	team, err := database.SelectTeamForUpdate(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team.CaptainID != captainID {
+		return nil, fmt.Errorf("only the captain of team %d can enter it", teamID)
+	}
+	members, err := database.SelectTeamMembers(tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	houseShare := rake(tournament)
+	fees, rakes := teamFees(tournament, houseShare, team, members)
+	for _, m := range members {
+		fee, memberRake := fees[m.PlayerID], rakes[m.PlayerID]
+		err = checkSpend(tx, m.PlayerID, tournament, fee, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		ok, err := database.DebitPlayer(tx, m.PlayerID, fee)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("user %d does not have enough points", m.PlayerID)
+		}
+		err = database.InsertUserIntoTournament(tx, tournamentID, m.PlayerID, fee, memberRake)
+		if err != nil {
+			return nil, err
+		}
+		err = database.InsertPurchase(tx, entity.Purchase{TournamentID: tournamentID, PlayerID: m.PlayerID,
+			Kind: entity.PurchaseEntry, Amount: fee, Rake: memberRake})
+		if err != nil {
+			return nil, err
+		}
+		err = collectRake(tx, tournamentID, m.PlayerID, memberRake)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = database.AddTournamentPrize(tx, tournamentID, tournament.Deposit-houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertTournamentTeam(tx, tournamentID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		err = database.InsertTournamentTeamMember(tx, tournamentID, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.JoinResults{PlayerID: team.CaptainID, TeamID: teamID, TournamentID: tournamentID, Status: entity.JoinStatusJoined})
+}
+
+// teamFees returns the part of the deposit and of the rake each member pays. Split fees are equal,
+// the remainders of the divisions are paid by the captain.
+func teamFees(tournament entity.Tournament, houseShare int64, team entity.Team, members []entity.TeamMember) (map[int]int64, map[int]int64) {
+	fees := make(map[int]int64, len(members))
+	rakes := make(map[int]int64, len(members))
+	if tournament.TeamFee != entity.TeamFeeSplit || len(members) == 0 {
+		fees[team.CaptainID] = tournament.Deposit
+		rakes[team.CaptainID] = houseShare
+		return fees, rakes
+	}
+	fee := tournament.Deposit / int64(len(members))
+	memberRake := houseShare / int64(len(members))
+	for _, m := range members {
+		fees[m.PlayerID] = fee
+		rakes[m.PlayerID] = memberRake
+	}
+	fees[team.CaptainID] += tournament.Deposit - fee*int64(len(members))
+	rakes[team.CaptainID] += houseShare - memberRake*int64(len(members))
+	return fees, rakes
+}
+
+// teamParticipants returns the teams of the tournament as participants, PlayerID holds the team id.
+func teamParticipants(tx *sql.Tx, tournamentID int) ([]entity.TournamentPlayer, error) {
+	teams, err := database.SelectTournamentTeams(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]entity.TournamentPlayer, 0, len(teams))
+	for _, t := range teams {
+		participants = append(participants, entity.TournamentPlayer{PlayerID: t.TeamID, TournamentID: tournamentID})
+	}
+	return participants, nil
+}
+
+// payTeams records the team results and divides every team prize among the members the team entered
+// with by their shares, the remainder of the division goes to the captain. It returns the member
+// standings, captains first.
+func payTeams(tx *sql.Tx, tournamentID int, teamStandings []entity.Standing) ([]entity.Standing, error) {
+	standings := make([]entity.Standing, 0, len(teamStandings))
+	for _, ts := range teamStandings {
+		err := database.UpdateTournamentTeamResult(tx, tournamentID, ts.PlayerID, ts.Place, ts.Prize)
+		if err != nil {
+			return nil, err
+		}
+		team, err := database.SelectTeam(tx, ts.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		members, err := database.SelectTournamentTeamMembers(tx, tournamentID, ts.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		total := 0.0
+		for _, m := range members {
+			total += m.Share
+		}
+		captain := entity.Standing{PlayerID: team.CaptainID, Place: ts.Place, Score: ts.Score, Prize: ts.Prize}
+		others := make([]entity.Standing, 0, len(members))
+		for _, m := range members {
+			if m.PlayerID == team.CaptainID {
+				continue
+			}
+			prize := int64(float64(ts.Prize) * m.Share / total)
+			captain.Prize -= prize
+			others = append(others, entity.Standing{PlayerID: m.PlayerID, Place: ts.Place, Score: ts.Score, Prize: prize})
+		}
+		standings = append(standings, captain)
+		standings = append(standings, others...)
+	}
+	return standings, nil
+}
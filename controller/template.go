@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// CreateTemplate stores the settings of a recurring tournament. The first tournament is
+// announced LeadMinutes before the first start time matching the recurrence rule.
+func CreateTemplate(db *sql.DB, template entity.Template) ([]byte, error) {
+	template.Active = true
+	err := prepareTemplate(&template, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	id, err := database.InsertTemplate(db, template)
+	if err != nil {
+		return nil, err
+	}
+	return GetTemplate(db, id)
+}
+
+// UpdateTemplate replaces the settings of the template, tournaments announced already are not changed.
+func UpdateTemplate(db *sql.DB, template entity.Template) ([]byte, error) {
+	current, err := database.SelectTemplate(db, template.ID)
+	if err != nil {
+		return nil, err
+	}
+	template.Active = current.Active
+	err = prepareTemplate(&template, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	err = database.UpdateTemplate(db, template)
+	if err != nil {
+		return nil, err
+	}
+	return GetTemplate(db, template.ID)
+}
+
+// SetTemplateActive pauses or resumes the template, a resumed template continues with
+// the next start time from now on.
+func SetTemplateActive(db *sql.DB, templateID int, active bool) ([]byte, error) {
+	template, err := database.SelectTemplate(db, templateID)
+	if err != nil {
+		return nil, err
+	}
+	template.Active = active
+	err = prepareTemplate(&template, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	err = database.UpdateTemplate(db, template)
+	if err != nil {
+		return nil, err
+	}
+	return GetTemplate(db, templateID)
+}
+
+// DeleteTemplate removes the template, tournaments announced from it stay.
+func DeleteTemplate(db *sql.DB, templateID int) error {
+	ok, err := database.DeleteTemplate(db, templateID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("template %d does not exist", templateID)
+	}
+	return nil
+}
+
+// GetTemplate get template from database layer.
+func GetTemplate(db *sql.DB, templateID int) ([]byte, error) {
+	template, err := database.SelectTemplate(db, templateID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(templateInfo(template))
+}
+
+// GetTemplates get all templates from database layer.
+func GetTemplates(db *sql.DB) ([]byte, error) {
+	templates, err := database.SelectTemplates(db)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.Templates{Templates: make([]entity.TemplateInfo, 0, len(templates))}
+	for _, t := range templates {
+		res.Templates = append(res.Templates, templateInfo(t))
+	}
+	return json.Marshal(res)
+}
+
+// RunTemplates announces the tournaments of all templates which are due at now and returns their ids.
+// Start times which have passed while nothing ran are skipped, the template continues with the next one.
+// A failing template is logged and retried on the next run, it doesn't hold up the other templates.
+func RunTemplates(db *sql.DB, now time.Time) ([]int, error) {
+	ids, err := database.SelectDueTemplates(db, now)
+	if err != nil {
+		return nil, err
+	}
+	announced := make([]int, 0, len(ids))
+	for _, id := range ids {
+		tournamentID, err := runTemplate(db, id, now)
+		if err != nil {
+			log.Printf("templates: template %d: %s", id, err)
+			continue
+		}
+		if tournamentID != 0 {
+			announced = append(announced, tournamentID)
+		}
+	}
+	return announced, nil
+}
+
+func runTemplate(db *sql.DB, templateID int, now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	template, err := database.SelectTemplateForUpdate(tx, templateID)
+	if err != nil {
+		return 0, err
+	}
+	if !template.Active || template.NextStartAt == nil {
+		return 0, nil
+	}
+	schedule, err := parseCron(template.Recurrence)
+	if err != nil {
+		return 0, err
+	}
+	start := *template.NextStartAt
+	tournamentID := 0
+	if start.After(now) {
+		tournamentID, err = database.NextTournamentID(tx)
+		if err != nil {
+			return 0, err
+		}
+		params := template.Params
+		params.ID = tournamentID
+		params.RegistrationClosesAt = &start
+		params.StartsAt = &start
+		err = announce(tx, params)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		start = now
+	}
+	var nextStart *time.Time
+	if next, ok := schedule.next(start); ok {
+		nextStart = &next
+	}
+	err = database.SetTemplateNextStart(tx, templateID, nextStart, tournamentID)
+	if err != nil {
+		return 0, err
+	}
+	return tournamentID, tx.Commit()
+}
+
+// prepareTemplate validates the template and computes its next start time.
+func prepareTemplate(template *entity.Template, now time.Time) error {
+	if template.Name == "" {
+		return fmt.Errorf("template name must not be empty")
+	}
+	if template.LeadMinutes <= 0 {
+		return fmt.Errorf("lead time must be positive")
+	}
+	params := template.Params
+	if params.RegistrationOpensAt != nil || params.RegistrationClosesAt != nil || params.StartsAt != nil || params.AddOnClosesAt != nil {
+		return fmt.Errorf("template times are given by the recurrence rule")
+	}
+	params.ID = 0
+	err := validateAnnounceParams(params)
+	if err != nil {
+		return err
+	}
+	template.Params = params
+	schedule, err := parseCron(template.Recurrence)
+	if err != nil {
+		return err
+	}
+	template.NextStartAt = nil
+	if !template.Active {
+		return nil
+	}
+	next, ok := schedule.next(now)
+	if !ok {
+		return fmt.Errorf("recurrence rule %q never matches", template.Recurrence)
+	}
+	template.NextStartAt = &next
+	return nil
+}
+
+func templateInfo(t entity.Template) entity.TemplateInfo {
+	return entity.TemplateInfo{
+		ID:               t.ID,
+		Name:             t.Name,
+		Recurrence:       t.Recurrence,
+		LeadMinutes:      t.LeadMinutes,
+		Active:           t.Active,
+		NextStartAt:      t.NextStartAt,
+		LastTournamentID: t.LastTournamentID,
+		Settings:         t.Params,
+	}
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// newTicketToken returns random hex encoded ticket token.
+func newTicketToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// awardTickets replaces the cash prizes of a satellite by tickets into its target tournament. The best
+// players get a ticket each as long as the prize covers the deposit of the target, the rest of the prize
+// is paid in cash to the next player, or to the winner when everybody got a ticket. When the target does
+// not accept players any more the ticket value is paid in cash instead.
+func awardTickets(tx *sql.Tx, tournament entity.Tournament, standings []entity.Standing) ([]entity.Standing, error) {
+	target, err := database.SelectTournament(tx, tournament.SatelliteFor)
+	if err != nil {
+		return nil, err
+	}
+	value := target.Deposit
+	count := tournament.SatelliteTickets
+	if count > len(standings) {
+		count = len(standings)
+	}
+	if value <= 0 {
+		count = 0
+	} else if int64(count) > tournament.Prize/value {
+		count = int(tournament.Prize / value)
+	}
+	open := target.Status == entity.TournamentIsAnnounced || target.Status == entity.TournamentIsScheduled
+	for i := range standings {
+		standings[i].Prize = 0
+	}
+	for i := 0; i < count; i++ {
+		if !open {
+			standings[i].Prize = value
+			continue
+		}
+		token, err := newTicketToken()
+		if err != nil {
+			return nil, err
+		}
+		_, err = database.InsertTicket(tx, entity.Ticket{
+			Token:              token,
+			PlayerID:           standings[i].PlayerID,
+			SourceTournamentID: tournament.ID,
+			TournamentID:       target.ID,
+			Value:              value,
+			Unused:             tournament.TicketUnused,
+		})
+		if err != nil {
+			return nil, err
+		}
+		standings[i].Ticket = token
+	}
+	rest := tournament.Prize - int64(count)*value
+	if count < len(standings) {
+		standings[count].Prize += rest
+	} else {
+		standings[0].Prize += rest
+	}
+	return standings, nil
+}
+
+// JoinTournamentWithTicket enters the player into the tournament with a satellite ticket instead of the deposit.
+func JoinTournamentWithTicket(db *sql.DB, playerID int, tournamentID int, token string) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if !registrationIsOpen(tournament, time.Now()) {
+		return nil, fmt.Errorf("tournment is closed")
+	}
+	if tournament.TeamMode {
+		return nil, fmt.Errorf("tournament %d is joined by teams", tournamentID)
+	}
+	if tournament.MaxPlayers > 0 {
+		count, err := database.CountTournamentUsers(tx, tournamentID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= tournament.MaxPlayers {
+			return nil, fmt.Errorf("tournament %d is full", tournamentID)
+		}
+	}
+	ticket, err := database.SelectTicketForUpdate(tx, token)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown ticket")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ticket.PlayerID != playerID || ticket.TournamentID != tournamentID {
+		return nil, fmt.Errorf("ticket is not valid for user %d in tournament %d", playerID, tournamentID)
+	}
+	if ticket.Status != entity.TicketIssued {
+		return nil, fmt.Errorf("ticket is %s", ticket.Status)
+	}
+	// the ticket was paid in the satellite, only the account and the verification are checked
+	err = checkSpend(tx, playerID, tournament, 0, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	houseShare := rake(tournament)
+	if houseShare > ticket.Value {
+		houseShare = ticket.Value
+	}
+	err = database.AddTournamentPrize(tx, tournamentID, ticket.Value-houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertUserIntoTournament(tx, tournamentID, playerID, ticket.Value, houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = database.SetTournamentUserTicket(tx, tournamentID, playerID, ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = database.ChangeTicketStatus(tx, ticket.ID, entity.TicketUsed)
+	if err != nil {
+		return nil, err
+	}
+	err = database.InsertPurchase(tx, entity.Purchase{TournamentID: tournamentID, PlayerID: playerID,
+		Kind: entity.PurchaseTicketEntry, Amount: ticket.Value, Rake: houseShare})
+	if err != nil {
+		return nil, err
+	}
+	err = collectRake(tx, tournamentID, playerID, houseShare)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.JoinResults{PlayerID: playerID, TournamentID: tournamentID, Status: entity.JoinStatusJoined, Ticket: token})
+}
+
+// settleTickets settles the tickets into the tournament which were not used once its registration is over.
+// Tickets into a cancelled tournament are always paid out, otherwise the policy of the satellite decides.
+func settleTickets(tx *sql.Tx, tournamentID int, cancelled bool) error {
+	tickets, err := database.SelectIssuedTickets(tx, tournamentID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tickets {
+		if cancelled || t.Unused == entity.TicketCash {
+			err = database.AddPlayerPoints(tx, t.PlayerID, t.Value)
+			if err == nil {
+				err = database.ChangeTicketStatus(tx, t.ID, entity.TicketCashed)
+			}
+		} else {
+			err = database.ChangeHouseBalance(tx, database.HouseAccountID, t.Value)
+			if err == nil {
+				err = database.InsertHouseLedgerEntry(tx, entity.HouseLedgerEntry{
+					HouseID:      database.HouseAccountID,
+					TournamentID: t.SourceTournamentID,
+					PlayerID:     t.PlayerID,
+					Amount:       t.Value,
+					Kind:         entity.LedgerKindTicket,
+				})
+			}
+			if err == nil {
+				err = database.ChangeTicketStatus(tx, t.ID, entity.TicketExpired)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTickets returns the tickets of the player.
+func GetTickets(db *sql.DB, playerID int) ([]byte, error) {
+	tickets, err := database.SelectPlayerTickets(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	res := entity.Tickets{PlayerID: playerID, Tickets: make([]entity.TicketInfo, 0, len(tickets))}
+	for _, t := range tickets {
+		res.Tickets = append(res.Tickets, entity.TicketInfo{
+			Token:              t.Token,
+			TournamentID:       t.TournamentID,
+			SourceTournamentID: t.SourceTournamentID,
+			Value:              toPoints(t.Value),
+			Unused:             t.Unused,
+			Status:             t.Status,
+			CreatedAt:          t.CreatedAt,
+			SettledAt:          t.SettledAt,
+		})
+	}
+	return json.Marshal(res)
+}
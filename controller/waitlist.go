@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mishelini/database"
+	"github.com/mishelini/entity"
+)
+
+// waitlist puts the player at the end of the tournament waitlist and commits the transaction.
+func waitlist(tx *sql.Tx, tournament entity.Tournament, playerID int) ([]byte, error) {
+	_, err := database.SelectTournamentUser(tx, tournament.ID, playerID)
+	if err == nil {
+		return nil, fmt.Errorf("user %d already takes part in tournament %d", playerID, tournament.ID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	position, err := database.InsertWaitlistEntry(tx, tournament.ID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entity.JoinResults{
+		PlayerID:     playerID,
+		TournamentID: tournament.ID,
+		Status:       entity.JoinStatusWaitlisted,
+		Position:     position,
+	})
+}
+
+// LeaveTournament removes the player from the tournament or from its waitlist while registration is open.
+// The paid deposit is refunded and the freed place goes to the next player on the waitlist. Players whose
+// account is not active may still leave, leaving takes no money from them.
+func LeaveTournament(db *sql.DB, playerID int, tournamentID int) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tournament, err := database.SelectTournamentForUpdate(tx, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status != entity.TournamentIsAnnounced {
+		return nil, fmt.Errorf("tournament %d is %s", tournamentID, entity.TournamentStatusName(tournament.Status))
+	}
+	if tournament.TeamMode {
+		return nil, fmt.Errorf("players can not leave team tournament %d", tournamentID)
+	}
+	res := entity.LeaveResults{PlayerID: playerID, TournamentID: tournamentID}
+	entry, err := database.SelectTournamentUser(tx, tournamentID, playerID)
+	switch {
+	case err == sql.ErrNoRows:
+		removed, err := database.DeleteWaitlistEntry(tx, tournamentID, playerID)
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			return nil, fmt.Errorf("user %d does not take part in tournament %d", playerID, tournamentID)
+		}
+	case err != nil:
+		return nil, err
+	default:
+		refund, err := removeEntry(tx, &tournament, entry)
+		if err != nil {
+			return nil, err
+		}
+		res.Refund = toPoints(refund)
+		res.PromotedPlayerID, err = promoteWaitlist(tx, tournament)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// removeEntry removes the player from the tournament, refunds the entry and takes it out of the prize.
+// It returns the points refunded.
+func removeEntry(tx *sql.Tx, tournament *entity.Tournament, entry entity.TournamentPlayer) (int64, error) {
+	err := database.DeleteUserFromTournament(tx, tournament.ID, entry.PlayerID)
+	if err != nil {
+		return 0, err
+	}
+	refund, err := refundEntry(tx, entry)
+	if err != nil {
+		return 0, err
+	}
+	err = database.AddTournamentPrize(tx, tournament.ID, -(entry.Paid - entry.Rake))
+	if err != nil {
+		return 0, err
+	}
+	tournament.Prize -= entry.Paid - entry.Rake
+	return refund, nil
+}
+
+// promoteWaitlist gives the free place to the first waitlisted player who can still pay the deposit.
+// Players who can't afford it any more are dropped from the waitlist. It returns the promoted player id or 0.
+func promoteWaitlist(tx *sql.Tx, tournament entity.Tournament) (int, error) {
+	entries, err := database.SelectWaitlist(tx, tournament.ID)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		_, err = database.DeleteWaitlistEntry(tx, tournament.ID, entry.PlayerID)
+		if err != nil {
+			return 0, err
+		}
+		player, err := database.SelectPlayer(tx, entry.PlayerID)
+		if err != nil {
+			return 0, err
+		}
+		if player.Points < tournament.Deposit {
+			log.Printf("waitlist: user %d skipped for tournament %d, not enough points", entry.PlayerID, tournament.ID)
+			continue
+		}
+		err = checkSpend(tx, entry.PlayerID, tournament, tournament.Deposit, time.Now())
+		if _, ok := err.(limitError); ok {
+			log.Printf("waitlist: user %d skipped for tournament %d, %s", entry.PlayerID, tournament.ID, err)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		err = enterTournament(tx, tournament, entry.PlayerID)
+		if err != nil {
+			return 0, err
+		}
+		return entry.PlayerID, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// SetPlayerStatus change the account status of the player and record the change in the status history.
+func SetPlayerStatus(db Queryer, change entity.AccountStatusChange) error {
+	id := 0
+	err := db.QueryRow(`UPDATE player SET status = $2, status_reason = $3, excluded_until = $4, status_changed_at = now()
+		WHERE id = $1 RETURNING id`, change.PlayerID, change.Status, change.Reason, change.ExcludedUntil).Scan(&id)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO player_status_history (player_id, status, reason, excluded_until, actor)
+		VALUES($1, $2, $3, $4, $5)`, change.PlayerID, change.Status, change.Reason, change.ExcludedUntil, change.Actor)
+	return err
+}
+
+// SelectPlayerStatusHistory select account status changes of the player, newest first.
+func SelectPlayerStatusHistory(db Queryer, playerID int) ([]entity.AccountStatusChange, error) {
+	changes := make([]entity.AccountStatusChange, 0)
+	rows, err := db.Query(`SELECT player_id, status, reason, excluded_until, actor, created_at
+		FROM player_status_history WHERE player_id = $1 ORDER BY id DESC`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c entity.AccountStatusChange
+		if err := rows.Scan(&c.PlayerID, &c.Status, &c.Reason, &c.ExcludedUntil, &c.Actor, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// SelectPlayerOpenEntries select entries of the player in single player tournaments which have not started yet.
+func SelectPlayerOpenEntries(db Queryer, playerID int) ([]entity.TournamentPlayer, error) {
+	entries := make([]entity.TournamentPlayer, 0)
+	rows, err := db.Query(`SELECT tp.tournament_id FROM tournament_player tp JOIN tournament t ON t.id = tp.tournament_id
+		WHERE tp.player_id = $1 AND t.status IN ($2, $3) AND NOT t.team_mode ORDER BY tp.tournament_id`,
+		playerID, entity.TournamentIsAnnounced, entity.TournamentIsRegistrationClosed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entity.TournamentPlayer
+		if err := rows.Scan(&e.TournamentID); err != nil {
+			return nil, err
+		}
+		e.PlayerID = playerID
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// SelectPlayerWaitlists select ids of the tournaments the player is waitlisted for.
+func SelectPlayerWaitlists(db Queryer, playerID int) ([]int, error) {
+	ids := make([]int, 0)
+	rows, err := db.Query("SELECT tournament_id FROM tournament_waitlist WHERE player_id = $1 ORDER BY tournament_id", playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+const apiKeyColumns = `id, name, key_hash, scope, created_at, revoked_at`
+
+func scanAPIKey(row scanner) (entity.APIKey, error) {
+	var k entity.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Hash, &k.Scope, &k.CreatedAt, &k.RevokedAt)
+	return k, err
+}
+
+// InsertAPIKey insert new API key and return its id.
+func InsertAPIKey(db Queryer, key entity.APIKey) (int, error) {
+	id := 0
+	err := db.QueryRow("INSERT INTO api_key (name, key_hash, scope) VALUES($1, $2, $3) RETURNING id",
+		key.Name, key.Hash, key.Scope).Scan(&id)
+	return id, err
+}
+
+// SelectAPIKey select API key by id.
+func SelectAPIKey(db Queryer, keyID int) (entity.APIKey, error) {
+	return scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE id = $1", keyID))
+}
+
+// SelectActiveAPIKeyByHash select API key which is not revoked by the hash of the key.
+func SelectActiveAPIKeyByHash(db Queryer, hash string) (entity.APIKey, error) {
+	return scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = $1 AND revoked_at IS NULL", hash))
+}
+
+// SelectAPIKeys select all API keys ordered by id.
+func SelectAPIKeys(db Queryer) ([]entity.APIKey, error) {
+	keys := make([]entity.APIKey, 0)
+	rows, err := db.Query("SELECT " + apiKeyColumns + " FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revoke API key by id, it returns false when there is no such active key.
+func RevokeAPIKey(db Queryer, keyID int) (bool, error) {
+	res, err := db.Exec("UPDATE api_key SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", keyID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
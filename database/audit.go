@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mishelini/entity"
+)
+
+const auditColumns = `id, actor, action, target, before, after, source_ip, request_id, created_at, prev_hash, hash`
+
+func scanAuditRecord(row scanner) (entity.AuditRecord, error) {
+	var a entity.AuditRecord
+	var before, after string
+	err := row.Scan(&a.ID, &a.Actor, &a.Action, &a.Target, &before, &after, &a.SourceIP, &a.RequestID, &a.CreatedAt, &a.PrevHash, &a.Hash)
+	if before != "" {
+		a.Before = json.RawMessage(before)
+	}
+	if after != "" {
+		a.After = json.RawMessage(after)
+	}
+	return a, err
+}
+
+// LockAuditLog lock the audit log against other writers until the end of the transaction,
+// so the records are chained one after another.
+func LockAuditLog(tx *sql.Tx) error {
+	_, err := tx.Exec("LOCK TABLE audit_log IN SHARE ROW EXCLUSIVE MODE")
+	return err
+}
+
+// SelectLastAuditHash select hash of the newest audit record, empty when the log is empty.
+func SelectLastAuditHash(db Queryer) (string, error) {
+	hash := ""
+	err := db.QueryRow("SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// InsertAuditRecord insert new audit record and return its id.
+func InsertAuditRecord(db Queryer, a entity.AuditRecord) (int, error) {
+	id := 0
+	err := db.QueryRow(`INSERT INTO audit_log (actor, action, target, before, after, source_ip, request_id, created_at, prev_hash, hash)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		a.Actor, a.Action, a.Target, string(a.Before), string(a.After), a.SourceIP, a.RequestID, a.CreatedAt, a.PrevHash, a.Hash).Scan(&id)
+	return id, err
+}
+
+// SelectAuditRecords select audit records matching the filter, oldest first.
+func SelectAuditRecords(db Queryer, filter entity.AuditFilter) ([]entity.AuditRecord, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.Target != "" {
+		add("target = $%d", filter.Target)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	records := make([]entity.AuditRecord, 0)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAuditRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, a)
+	}
+	return records, rows.Err()
+}
@@ -3,12 +3,43 @@ package database
 import (
 	"database/sql"
 
+	"github.com/lib/pq"
 	"github.com/mishelini/entity"
 )
 
 // InitData bool param for test data
 var InitData = true
 
+// Queryer is implemented by both *sql.DB and *sql.Tx,
+// so database functions can run inside or outside a transaction.
+type Queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const tournamentColumns = `id, deposit, prize, winner, status, rake_percent, rake_fixed, guarantee, overlay,
+	max_players, min_players, registration_opens_at, registration_closes_at, starts_at,
+	format, payouts, server_seed_hash, swiss_rounds, team_mode, team_fee, score_mode,
+	max_re_entries, add_on_price, max_add_ons, add_on_closes_at, satellite_for, satellite_tickets, ticket_unused`
+
+func scanTournament(row scanner) (entity.Tournament, error) {
+	var t entity.Tournament
+	err := row.Scan(&t.ID, &t.Deposit, &t.Prize, &t.Winner, &t.Status, &t.RakePercent, &t.RakeFixed,
+		&t.Guarantee, &t.Overlay, &t.MaxPlayers, &t.MinPlayers,
+		&t.RegistrationOpensAt, &t.RegistrationClosesAt, &t.StartsAt,
+		&t.Format, (*pq.Float64Array)(&t.Payouts), &t.ServerSeedHash, &t.SwissRounds,
+		&t.TeamMode, &t.TeamFee, &t.ScoreMode,
+		&t.MaxReEntries, &t.AddOnPrice, &t.MaxAddOns, &t.AddOnClosesAt,
+		&t.SatelliteFor, &t.SatelliteTickets, &t.TicketUnused)
+	return t, err
+}
+
 // CreateTablesIfNotExist   database initializing and adding test data.
 func CreateTablesIfNotExist(db *sql.DB) error {
 
@@ -36,6 +67,275 @@ func CreateTablesIfNotExist(db *sql.DB) error {
 	   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
 	   CONSTRAINT tournament_player_pkey PRIMARY KEY (player_id, tournament_id)
 	);
+
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS excluded_until TIMESTAMPTZ;
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS kyc_status VARCHAR(20) NOT NULL DEFAULT 'unverified';
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS kyc_note TEXT NOT NULL DEFAULT '';
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS kyc_reviewed_at TIMESTAMPTZ;
+	ALTER TABLE player ADD COLUMN IF NOT EXISTS kyc_reviewer VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS rake_percent DOUBLE PRECISION NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS rake_fixed BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS guarantee BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS overlay BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS max_players INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS min_players INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS registration_opens_at TIMESTAMPTZ;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS registration_closes_at TIMESTAMPTZ;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS format VARCHAR(20) NOT NULL DEFAULT 'raffle';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS payouts DOUBLE PRECISION[] NOT NULL DEFAULT '{100}';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS swiss_rounds INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS server_seed VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS server_seed_hash VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS client_seed VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS draw_players INT[];
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS team_mode BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS team_fee VARCHAR(10) NOT NULL DEFAULT 'captain';
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS score_mode VARCHAR(10) NOT NULL DEFAULT 'best';
+	ALTER TABLE tournament_player ADD COLUMN IF NOT EXISTS paid BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament_player ADD COLUMN IF NOT EXISTS rake BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS max_re_entries INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS add_on_price BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS max_add_ons INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS add_on_closes_at TIMESTAMPTZ;
+	ALTER TABLE tournament_player ADD COLUMN IF NOT EXISTS entries INT NOT NULL DEFAULT 1;
+	ALTER TABLE tournament_player ADD COLUMN IF NOT EXISTS add_ons INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS satellite_for INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS satellite_tickets INT NOT NULL DEFAULT 0;
+	ALTER TABLE tournament ADD COLUMN IF NOT EXISTS ticket_unused VARCHAR(10) NOT NULL DEFAULT 'expire';
+	ALTER TABLE tournament_player ADD COLUMN IF NOT EXISTS ticket_id INT NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS ticket
+	(
+	   id                   SERIAL PRIMARY KEY,
+	   token                VARCHAR(64) UNIQUE NOT NULL,
+	   player_id            INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   source_tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   tournament_id        INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   value                BIGINT NOT NULL,
+	   unused               VARCHAR(10) NOT NULL,
+	   status               VARCHAR(10) NOT NULL DEFAULT 'issued',
+	   created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+	   settled_at           TIMESTAMPTZ
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_purchase
+	(
+	   id            SERIAL PRIMARY KEY,
+	   tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   player_id     INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   kind          VARCHAR(20) NOT NULL,
+	   amount        BIGINT NOT NULL,
+	   rake          BIGINT NOT NULL DEFAULT 0,
+	   created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_result
+	(
+	   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
+	   player_id     INT REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   place         INT NOT NULL,
+	   score         DOUBLE PRECISION,
+	   prize         BIGINT NOT NULL DEFAULT 0,
+	   CONSTRAINT tournament_result_pkey PRIMARY KEY (tournament_id, player_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_match
+	(
+	   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
+	   number        INT NOT NULL,
+	   bracket       VARCHAR(2) NOT NULL,
+	   round         INT NOT NULL,
+	   position      INT NOT NULL,
+	   player1       INT NOT NULL DEFAULT 0,
+	   player2       INT NOT NULL DEFAULT 0,
+	   score1        DOUBLE PRECISION NOT NULL DEFAULT 0,
+	   score2        DOUBLE PRECISION NOT NULL DEFAULT 0,
+	   winner        INT NOT NULL DEFAULT 0,
+	   status        VARCHAR(10) NOT NULL,
+	   filled        INT NOT NULL DEFAULT 0,
+	   winner_to     INT NOT NULL DEFAULT 0,
+	   winner_slot   INT NOT NULL DEFAULT 0,
+	   loser_to      INT NOT NULL DEFAULT 0,
+	   loser_slot    INT NOT NULL DEFAULT 0,
+	   CONSTRAINT tournament_match_pkey PRIMARY KEY (tournament_id, number)
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_waitlist
+	(
+	   id            SERIAL PRIMARY KEY,
+	   tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   player_id     INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+	   UNIQUE (tournament_id, player_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_score
+	(
+	   id            SERIAL PRIMARY KEY,
+	   tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   player_id     INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   score         DOUBLE PRECISION NOT NULL,
+	   created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS tournament_score_tournament_idx ON tournament_score (tournament_id, player_id);
+
+	CREATE TABLE IF NOT EXISTS tournament_template
+	(
+	   id                 SERIAL PRIMARY KEY,
+	   name               VARCHAR(50) UNIQUE NOT NULL,
+	   settings           JSONB NOT NULL,
+	   recurrence         VARCHAR(100) NOT NULL,
+	   lead_minutes       INT NOT NULL,
+	   active             BOOLEAN NOT NULL DEFAULT true,
+	   next_start_at      TIMESTAMPTZ,
+	   last_tournament_id INT NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS team
+	(
+	   id         SERIAL PRIMARY KEY,
+	   name       VARCHAR(50) UNIQUE NOT NULL,
+	   captain_id INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS team_member
+	(
+	   team_id   INT NOT NULL REFERENCES team (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   player_id INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   share     DOUBLE PRECISION NOT NULL DEFAULT 1,
+	   CONSTRAINT team_member_pkey PRIMARY KEY (team_id, player_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_team
+	(
+	   tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   team_id       INT NOT NULL REFERENCES team (id) ON UPDATE CASCADE,
+	   place         INT NOT NULL DEFAULT 0,
+	   prize         BIGINT NOT NULL DEFAULT 0,
+	   CONSTRAINT tournament_team_pkey PRIMARY KEY (tournament_id, team_id)
+	);
+
+	ALTER TABLE team_member ADD COLUMN IF NOT EXISTS accepted BOOLEAN NOT NULL DEFAULT true;
+
+	CREATE TABLE IF NOT EXISTS tournament_team_member
+	(
+	   tournament_id INT NOT NULL REFERENCES tournament (id) ON UPDATE CASCADE,
+	   team_id       INT NOT NULL REFERENCES team (id) ON UPDATE CASCADE,
+	   player_id     INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE,
+	   share         DOUBLE PRECISION NOT NULL,
+	   CONSTRAINT tournament_team_member_pkey PRIMARY KEY (tournament_id, team_id, player_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS api_key
+	(
+	   id         SERIAL PRIMARY KEY,
+	   name       VARCHAR(50) NOT NULL,
+	   key_hash   VARCHAR(64) UNIQUE NOT NULL,
+	   scope      VARCHAR(20) NOT NULL,
+	   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	   revoked_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log
+	(
+	   id         SERIAL PRIMARY KEY,
+	   actor      VARCHAR(100) NOT NULL,
+	   action     VARCHAR(30) NOT NULL,
+	   target     VARCHAR(50) NOT NULL,
+	   before     TEXT NOT NULL DEFAULT '',
+	   after      TEXT NOT NULL DEFAULT '',
+	   source_ip  VARCHAR(45) NOT NULL DEFAULT '',
+	   request_id VARCHAR(64) NOT NULL DEFAULT '',
+	   created_at TIMESTAMPTZ NOT NULL,
+	   prev_hash  VARCHAR(64) NOT NULL,
+	   hash       VARCHAR(64) UNIQUE NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS request_nonce
+	(
+	   integration VARCHAR(50) NOT NULL,
+	   nonce       VARCHAR(64) NOT NULL,
+	   expires_at  TIMESTAMPTZ NOT NULL,
+	   CONSTRAINT request_nonce_pkey PRIMARY KEY (integration, nonce)
+	);
+
+	CREATE TABLE IF NOT EXISTS player_deposit
+	(
+	   id         SERIAL PRIMARY KEY,
+	   player_id  INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   amount     BIGINT NOT NULL,
+	   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS player_deposit_player_idx ON player_deposit (player_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS player_status_history
+	(
+	   id             SERIAL PRIMARY KEY,
+	   player_id      INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   status         VARCHAR(20) NOT NULL,
+	   reason         TEXT NOT NULL DEFAULT '',
+	   excluded_until TIMESTAMPTZ,
+	   actor          VARCHAR(100) NOT NULL DEFAULT '',
+	   created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS kyc_document
+	(
+	   id         SERIAL PRIMARY KEY,
+	   player_id  INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   kind       VARCHAR(30) NOT NULL,
+	   reference  VARCHAR(200) NOT NULL,
+	   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS player_withdrawal
+	(
+	   id         SERIAL PRIMARY KEY,
+	   player_id  INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   amount     BIGINT NOT NULL,
+	   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS player_limit
+	(
+	   player_id      INT NOT NULL REFERENCES player (id) ON UPDATE CASCADE ON DELETE CASCADE,
+	   kind           VARCHAR(20) NOT NULL,
+	   period         VARCHAR(20) NOT NULL,
+	   amount         BIGINT NOT NULL,
+	   pending_amount BIGINT NOT NULL DEFAULT 0,
+	   pending_from   TIMESTAMPTZ,
+	   CONSTRAINT player_limit_pkey PRIMARY KEY (player_id, kind, period)
+	);
+
+	CREATE TABLE IF NOT EXISTS scheduler_lock
+	(
+	   name       VARCHAR(30) PRIMARY KEY,
+	   holder     VARCHAR(100) NOT NULL,
+	   expires_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS house_account
+	(
+	   id      SERIAL PRIMARY KEY,
+	   name    VARCHAR(30) UNIQUE NOT NULL,
+	   balance BIGINT NOT NULL DEFAULT 0
+	);
+	INSERT INTO house_account (id, name) VALUES (1, 'house') ON CONFLICT DO NOTHING;
+
+	CREATE TABLE IF NOT EXISTS house_ledger
+	(
+	   id            SERIAL PRIMARY KEY,
+	   house_id      INT NOT NULL REFERENCES house_account (id),
+	   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
+	   player_id     INT REFERENCES player (id) ON UPDATE CASCADE ON DELETE SET NULL,
+	   amount        BIGINT NOT NULL,
+	   kind          VARCHAR(20) NOT NULL,
+	   created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
 	`
 	if InitData == true {
 		addUserQuery := `
@@ -45,50 +345,101 @@ func CreateTablesIfNotExist(db *sql.DB) error {
 		createTablesQuery = createTablesQuery + addUserQuery
 	}
 
-	_, err := db.Query(createTablesQuery)
+	_, err := db.Exec(createTablesQuery)
 	return err
 }
 
 // FundPlayer  update user points.
-func FundPlayer(db *sql.DB, playerID int, points int64) error {
+func FundPlayer(db Queryer, playerID int, points int64) error {
 	id := 0
 	err := db.QueryRow("UPDATE player SET points = $1 WHERE id = $2 RETURNING id", points, playerID).Scan(&id)
 	return err
 }
 
 // AnnounceTournaments  insert new  tournament.
-func AnnounceTournaments(db *sql.DB, tournamentID int, deposit int64) error {
+func AnnounceTournaments(db Queryer, tournament entity.Tournament) error {
 	id := 0
-	err := db.QueryRow("INSERT INTO tournament (id, deposit)  VALUES($1, $2) RETURNING id", tournamentID, deposit).Scan(&id)
+	if tournament.Format == "" {
+		tournament.Format = entity.FormatRaffle
+	}
+	if len(tournament.Payouts) == 0 {
+		tournament.Payouts = []float64{100}
+	}
+	if tournament.TeamFee == "" {
+		tournament.TeamFee = entity.TeamFeeCaptain
+	}
+	if tournament.ScoreMode == "" {
+		tournament.ScoreMode = entity.ScoreModeBest
+	}
+	if tournament.TicketUnused == "" {
+		tournament.TicketUnused = entity.TicketExpire
+	}
+	err := db.QueryRow(`INSERT INTO tournament (id, deposit, status, rake_percent, rake_fixed, guarantee, max_players, min_players,
+		registration_opens_at, registration_closes_at, starts_at, format, payouts, swiss_rounds, team_mode, team_fee,
+		score_mode, max_re_entries, add_on_price, max_add_ons, add_on_closes_at, satellite_for, satellite_tickets, ticket_unused)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
+		RETURNING id`,
+		tournament.ID, tournament.Deposit, tournament.Status, tournament.RakePercent, tournament.RakeFixed, tournament.Guarantee,
+		tournament.MaxPlayers, tournament.MinPlayers,
+		tournament.RegistrationOpensAt, tournament.RegistrationClosesAt, tournament.StartsAt,
+		tournament.Format, pq.Float64Array(tournament.Payouts), tournament.SwissRounds,
+		tournament.TeamMode, tournament.TeamFee, tournament.ScoreMode,
+		tournament.MaxReEntries, tournament.AddOnPrice, tournament.MaxAddOns, tournament.AddOnClosesAt,
+		tournament.SatelliteFor, tournament.SatelliteTickets, tournament.TicketUnused).Scan(&id)
+	if err != nil {
+		return err
+	}
+	// tournaments are announced with ids chosen by the caller, move the sequence past them
+	// so NextTournamentID doesn't hand them out again
+	_, err = db.Exec(`SELECT setval(pg_get_serial_sequence('tournament', 'id'),
+		GREATEST($1, nextval(pg_get_serial_sequence('tournament', 'id'))))`, id)
 	return err
 }
 
-// SelectPlayer select player by id.
-func SelectPlayer(db *sql.DB, playerID int) (entity.Player, error) {
+const playerColumns = `id, first_name, points, excluded_until, status, status_reason, status_changed_at,
+	kyc_status, kyc_note, kyc_reviewed_at, kyc_reviewer`
+
+func scanPlayer(row scanner) (entity.Player, error) {
 	var player entity.Player
-	row := db.QueryRow("SELECT * FROM player WHERE id = $1 ", playerID)
-	err := row.Scan(&player.ID, &player.FirstName, &player.Points)
+	err := row.Scan(&player.ID, &player.FirstName, &player.Points, &player.ExcludedUntil,
+		&player.Status, &player.StatusReason, &player.StatusChangedAt,
+		&player.KYCStatus, &player.KYCNote, &player.KYCReviewedAt, &player.KYCReviewer)
 	return player, err
 }
 
+// SelectPlayer select player by id.
+func SelectPlayer(db Queryer, playerID int) (entity.Player, error) {
+	return scanPlayer(db.QueryRow("SELECT "+playerColumns+" FROM player WHERE id = $1 ", playerID))
+}
+
+// SelectPlayerForUpdate select player by id and lock it until the end of the transaction.
+func SelectPlayerForUpdate(tx *sql.Tx, playerID int) (entity.Player, error) {
+	return scanPlayer(tx.QueryRow("SELECT "+playerColumns+" FROM player WHERE id = $1 FOR UPDATE", playerID))
+}
+
 // SelectTournament select tournament by id.
-func SelectTournament(db *sql.DB, tournamentID int) (entity.Tournament, error) {
-	var tournament entity.Tournament
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", tournamentID)
-	err := row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
-	return tournament, err
+func SelectTournament(db Queryer, tournamentID int) (entity.Tournament, error) {
+	return scanTournament(db.QueryRow("SELECT "+tournamentColumns+" FROM tournament WHERE id = $1 ", tournamentID))
+}
+
+// SelectTournamentForUpdate select tournament by id and lock the row until the end of the transaction.
+func SelectTournamentForUpdate(tx *sql.Tx, tournamentID int) (entity.Tournament, error) {
+	return scanTournament(tx.QueryRow("SELECT "+tournamentColumns+" FROM tournament WHERE id = $1 FOR UPDATE", tournamentID))
 }
 
 // SelectTournamentUsers select  tournament players by tournament id.
-func SelectTournamentUsers(db *sql.DB, tournamentID int) ([]entity.TournamentPlayer, error) {
+func SelectTournamentUsers(db Queryer, tournamentID int) ([]entity.TournamentPlayer, error) {
 	players := make([]entity.TournamentPlayer, 0)
-	rows, err := db.Query(`SELECT * FROM tournament_player WHERE tournament_id = $1 `, tournamentID)
+	rows, err := db.Query(`SELECT player_id, tournament_id, paid, rake, entries, add_ons, ticket_id FROM tournament_player
+		WHERE tournament_id = $1 `, tournamentID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var player entity.TournamentPlayer
-		if err := rows.Scan(&player.PlayerID, &player.TournamentID); err != nil {
+		if err := rows.Scan(&player.PlayerID, &player.TournamentID, &player.Paid, &player.Rake,
+			&player.Entries, &player.AddOns, &player.TicketID); err != nil {
 			return nil, err
 		}
 		players = append(players, player)
@@ -97,15 +448,16 @@ func SelectTournamentUsers(db *sql.DB, tournamentID int) ([]entity.TournamentPla
 }
 
 // SelectFinishedTournaments select finished tournaments.
-func SelectFinishedTournaments(db *sql.DB) ([]entity.Tournament, error) {
+func SelectFinishedTournaments(db Queryer) ([]entity.Tournament, error) {
 	tournaments := make([]entity.Tournament, 0)
-	rows, err := db.Query(`SELECT * FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
+	rows, err := db.Query(`SELECT `+tournamentColumns+` FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
-		var t entity.Tournament
-		if err := rows.Scan(&t.ID, &t.Deposit, &t.Prize, &t.Winner, &t.Status); err != nil {
+		t, err := scanTournament(rows)
+		if err != nil {
 			return nil, err
 		}
 		tournaments = append(tournaments, t)
@@ -114,21 +466,88 @@ func SelectFinishedTournaments(db *sql.DB) ([]entity.Tournament, error) {
 }
 
 // ChangeTournamentsPrize update  tournament  prize.
-func ChangeTournamentsPrize(db *sql.DB, tournamentID int, prize int64) error {
+func ChangeTournamentsPrize(db Queryer, tournamentID int, prize int64) error {
 	id := 0
 	err := db.QueryRow(`UPDATE tournament SET  prize = $1   WHERE id = $2 RETURNING id`, prize, tournamentID).Scan(&id)
 	return err
 }
 
-// InsertUserIntoTournament  insert user and tournament into  tournament_player table.
-func InsertUserIntoTournament(db *sql.DB, tournamentID int, playerID int) error {
+// AddTournamentOverlay add operator overlay to the tournament prize and remember its amount.
+func AddTournamentOverlay(db Queryer, tournamentID int, overlay int64) error {
+	id := 0
+	err := db.QueryRow(`UPDATE tournament SET prize = prize + $1, overlay = overlay + $1 WHERE id = $2 RETURNING id`,
+		overlay, tournamentID).Scan(&id)
+	return err
+}
+
+// InsertUserIntoTournament  insert user and tournament into  tournament_player table
+// together with the paid deposit and the rake taken from it.
+func InsertUserIntoTournament(db Queryer, tournamentID int, playerID int, paid int64, rake int64) error {
 	player_id := 0
-	err := db.QueryRow("INSERT INTO tournament_player (player_id , tournament_id ) VALUES( $1 ,$2 )  RETURNING player_id", playerID, tournamentID).Scan(&player_id)
+	err := db.QueryRow("INSERT INTO tournament_player (player_id , tournament_id, paid, rake ) VALUES( $1 ,$2, $3, $4 )  RETURNING player_id",
+		playerID, tournamentID, paid, rake).Scan(&player_id)
+	return err
+}
+
+// SelectTournamentUser select tournament player by tournament id and player id.
+func SelectTournamentUser(db Queryer, tournamentID int, playerID int) (entity.TournamentPlayer, error) {
+	var player entity.TournamentPlayer
+	row := db.QueryRow(`SELECT player_id, tournament_id, paid, rake, entries, add_ons, ticket_id FROM tournament_player
+		WHERE tournament_id = $1 AND player_id = $2`, tournamentID, playerID)
+	err := row.Scan(&player.PlayerID, &player.TournamentID, &player.Paid, &player.Rake, &player.Entries, &player.AddOns,
+		&player.TicketID)
+	return player, err
+}
+
+// DeleteUserFromTournament  delete user from  tournament_player table.
+func DeleteUserFromTournament(db Queryer, tournamentID int, playerID int) error {
+	player_id := 0
+	err := db.QueryRow("DELETE FROM tournament_player WHERE tournament_id = $1 AND player_id = $2 RETURNING player_id",
+		tournamentID, playerID).Scan(&player_id)
+	return err
+}
+
+// CountTournamentUsers count players taking part in tournament.
+func CountTournamentUsers(db Queryer, tournamentID int) (int, error) {
+	count := 0
+	err := db.QueryRow("SELECT count(*) FROM tournament_player WHERE tournament_id = $1", tournamentID).Scan(&count)
+	return count, err
+}
+
+// AddPlayerPoints add points (may be negative) to the player balance.
+func AddPlayerPoints(db Queryer, playerID int, points int64) error {
+	id := 0
+	err := db.QueryRow("UPDATE player SET points = points + $1 WHERE id = $2 RETURNING id", points, playerID).Scan(&id)
+	return err
+}
+
+// DebitPlayer take points from the player balance if it is large enough,
+// it returns false when the player does not have enough points.
+func DebitPlayer(db Queryer, playerID int, points int64) (bool, error) {
+	id := 0
+	err := db.QueryRow("UPDATE player SET points = points - $1 WHERE id = $2 AND points >= $1 RETURNING id", points, playerID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddTournamentPrize add amount (may be negative) to the tournament prize.
+func AddTournamentPrize(db Queryer, tournamentID int, amount int64) error {
+	id := 0
+	err := db.QueryRow("UPDATE tournament SET prize = prize + $1 WHERE id = $2 RETURNING id", amount, tournamentID).Scan(&id)
+	return err
+}
+
+// ChangeTournamentStatus update tournament status.
+func ChangeTournamentStatus(db Queryer, tournamentID int, status int) error {
+	id := 0
+	err := db.QueryRow("UPDATE tournament SET status = $1 WHERE id = $2 RETURNING id", status, tournamentID).Scan(&id)
 	return err
 }
 
 // FinishTournament update tournament status.
-func FinishTournament(db *sql.DB, tournamentID int, playerID int) error {
+func FinishTournament(db Queryer, tournamentID int, playerID int) error {
 	id := 0
 	err := db.QueryRow("UPDATE tournament SET  winner = $1, status = $2 WHERE id = $3  RETURNING id", playerID, entity.TournamentIsFinished, tournamentID).Scan(&id)
 	return err
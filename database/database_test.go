@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/mishelini/entity"
 	"github.com/stretchr/testify/assert"
@@ -31,46 +32,14 @@ var testTournament = entity.Tournament{
 }
 
 func getDBConnection() (*sql.DB, error) {
-	postgresConfig := fmt.Sprintf("host=%s port=%s   user=%s dbname=%s sslmode=%s  password=%s",
-		"localhost", "5432", "postgres", "postgres", "disable", "postgres")
+	postgresConfig := fmt.Sprintf("host=%s port=%s   user=%s dbname=%s sslmode=%s  password=%s search_path=%s",
+		"localhost", "5432", "postgres", "postgres", "disable", "postgres", "test_schema")
 	dbConn, err := sql.Open("postgres", postgresConfig)
 	return dbConn, err
 }
 func initTestDb(db *sql.DB) error {
-	new_schema_strings := []string{
-		`CREATE TABLE IF NOT EXISTS player
-		(
-		   id        SERIAL PRIMARY KEY,
-		   first_name VARCHAR(30),
-		   points     BIGINT DEFAULT 0
-		);`,
-		` CREATE TABLE IF NOT EXISTS tournament
-		(
-		   id      SERIAL NOT NULL PRIMARY KEY,
-		   deposit BIGINT NOT NULL DEFAULT 0,
-		   prize   BIGINT NOT NULL DEFAULT 0,
-		   status   INT DEFAULT 0,
-		   winner   INT DEFAULT 0
-		   
-		);`,
-		` CREATE TABLE IF NOT EXISTS tournament_player
-		(
-		   player_id     INT REFERENCES player (id) ON UPDATE CASCADE ON DELETE
-		   CASCADE,
-		   tournament_id INT REFERENCES tournament (id) ON UPDATE CASCADE,
-		   CONSTRAINT tournament_player_pkey PRIMARY KEY (player_id, tournament_id)
-		);`,
-		` INSERT INTO player (first_name, points) VALUES ('testuser', 0);`,
-		` INSERT INTO player (first_name, points) VALUES ('testuser2', 0);`,
-	}
-	for _, qstr := range new_schema_strings {
-		_, err := db.Exec(qstr)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-
+	InitData = true
+	return CreateTablesIfNotExist(db)
 }
 
 func prepareTestEnv() (*sql.DB, error) {
@@ -107,7 +76,7 @@ func insertUserIntoTournament(db *sql.DB, userID int, tournamentID int) error {
 }
 func selectFinishedTournaments(db *sql.DB) ([]entity.Tournament, error) {
 	tournaments := make([]entity.Tournament, 0)
-	rows, err := db.Query(`SELECT * FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
+	rows, err := db.Query(`SELECT id, deposit, prize, winner, status FROM tournament WHERE status = $1 `, entity.TournamentIsFinished)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +140,7 @@ func TestFundPlayer(t *testing.T) {
 	assert.NoError(t, err, "func initTestDb failed")
 	err = FundPlayer(db, testUser.ID, testUser.Points)
 	assert.NoError(t, err, "fuc FundPlayer return error")
-	row := db.QueryRow("SELECT * FROM player WHERE id = $1 ", testUser.ID)
+	row := db.QueryRow("SELECT id, first_name, points FROM player WHERE id = $1 ", testUser.ID)
 	err = row.Scan(&player.ID, &player.FirstName, &player.Points)
 	assert.NoError(t, err, "select player return error")
 	assert.Equal(t, testUser.Points, player.Points, "player points after funding should be equal")
@@ -185,9 +154,9 @@ func TestAnnounceTournaments(t *testing.T) {
 	assert.NoError(t, err, "func prepareTestEnv failed")
 	defer db.Close()
 
-	err = AnnounceTournaments(db, testTournament.ID, testTournament.Deposit)
+	err = AnnounceTournaments(db, testTournament)
 	assert.NoError(t, err, "func AnnounceTournaments failed")
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", 1)
+	row := db.QueryRow("SELECT id, deposit, prize, winner, status FROM tournament WHERE id = $1 ", 1)
 	err = row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
 	assert.NoError(t, err, "select tournament return error")
 	assert.Equal(t, testTournament.ID, tournament.ID, "no test tournament in db")
@@ -203,7 +172,7 @@ func TestSelectPlayer(t *testing.T) {
 
 	testPlayer, err := SelectPlayer(db, testUser.ID)
 	assert.NoError(t, err, "func SelectPlayer failed")
-	row := db.QueryRow("SELECT * FROM player WHERE id = $1 ", testUser.ID)
+	row := db.QueryRow("SELECT id, first_name, points FROM player WHERE id = $1 ", testUser.ID)
 	err = row.Scan(&player.ID, &player.FirstName, &player.Points)
 	assert.NoError(t, err, "selecting player return error")
 	assert.Equal(t, testPlayer.FirstName, player.FirstName, "test user not selected")
@@ -222,7 +191,7 @@ func TestSelectTournament(t *testing.T) {
 	assert.NoError(t, err, "func announceTestTournament failed")
 	firstTournament, err := SelectTournament(db, testTournament.ID)
 	assert.NoError(t, err, "func SelectTournament failed")
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", testTournament.ID)
+	row := db.QueryRow("SELECT id, deposit, prize, winner, status FROM tournament WHERE id = $1 ", testTournament.ID)
 	err = row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
 	assert.NoError(t, err, "selecting tournament return error")
 	assert.Equal(t, firstTournament.ID, tournament.ID, "test tournament not selected")
@@ -238,7 +207,7 @@ func TestInsertUserIntoTournament(t *testing.T) {
 
 	err = announceTestTournament(db, testTournament.ID, testTournament.Deposit)
 	assert.NoError(t, err, "func announceTestTournament1 failed")
-	err = InsertUserIntoTournament(db, testTournament.ID, testUser.ID)
+	err = InsertUserIntoTournament(db, testTournament.ID, testUser.ID, testTournament.Deposit, 0)
 	assert.NoError(t, err, "func InsertUserIntoTournament failed")
 
 	err = dropTestSchema(db)
@@ -257,7 +226,7 @@ func TestSelectTournamentUsers(t *testing.T) {
 	tournamentUserSet, err := SelectTournamentUsers(db, testTournament.ID)
 	assert.NoError(t, err, "func SelectTournament failed")
 	players := make([]entity.TournamentPlayer, 0)
-	rows, err := db.Query(`SELECT * FROM tournament_player WHERE tournament_id = $1 `, testTournament.ID)
+	rows, err := db.Query(`SELECT player_id, tournament_id FROM tournament_player WHERE tournament_id = $1 `, testTournament.ID)
 	assert.NoError(t, err, "select tournament_player set failed")
 	for rows.Next() {
 		var player entity.TournamentPlayer
@@ -281,7 +250,7 @@ func TestChangeTournamentsPrize(t *testing.T) {
 	assert.NoError(t, err, "func announceTestTournament failed")
 	err = ChangeTournamentsPrize(db, testTournament.ID, newPrize)
 	assert.NoError(t, err, "func ChangeTournamentsPrize failed")
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", testTournament.ID)
+	row := db.QueryRow("SELECT id, deposit, prize, winner, status FROM tournament WHERE id = $1 ", testTournament.ID)
 	err = row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
 	assert.NoError(t, err, "select tournament return error")
 	assert.Equal(t, newPrize, tournament.Prize, "no test tournament in db")
@@ -300,7 +269,7 @@ func TestFinishTournament(t *testing.T) {
 	assert.NoError(t, err, "func announceTestTournament failed")
 	err = FinishTournament(db, testTournament.ID, testUser.ID)
 	assert.NoError(t, err, "func FinishTournament failed")
-	row := db.QueryRow("SELECT * FROM tournament WHERE id = $1 ", testTournament.ID)
+	row := db.QueryRow("SELECT id, deposit, prize, winner, status FROM tournament WHERE id = $1 ", testTournament.ID)
 	err = row.Scan(&tournament.ID, &tournament.Deposit, &tournament.Prize, &tournament.Winner, &tournament.Status)
 	assert.NoError(t, err, "select tournament return error")
 	assert.Equal(t, entity.TournamentIsFinished, tournament.Status, "no test tournament in db")
@@ -325,3 +294,80 @@ func TestSelectFinishedTournaments(t *testing.T) {
 	err = dropTestSchema(db)
 	assert.NoError(t, err, "func dropTestSchema faild")
 }
+
+func TestHouseLedger(t *testing.T) {
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	err = announceTestTournament(db, testTournament.ID, testTournament.Deposit)
+	assert.NoError(t, err, "func announceTestTournament failed")
+	err = ChangeHouseBalance(db, HouseAccountID, 20)
+	assert.NoError(t, err, "func ChangeHouseBalance failed")
+	err = InsertHouseLedgerEntry(db, entity.HouseLedgerEntry{HouseID: HouseAccountID, TournamentID: testTournament.ID,
+		PlayerID: testUser.ID, Amount: 20, Kind: entity.LedgerKindRake})
+	assert.NoError(t, err, "func InsertHouseLedgerEntry failed")
+
+	house, err := SelectHouseAccount(db, HouseAccountID)
+	assert.NoError(t, err, "func SelectHouseAccount failed")
+	assert.Equal(t, int64(20), house.Balance, "house balance should be changed")
+	entries, err := SelectHouseLedger(db, HouseAccountID)
+	assert.NoError(t, err, "func SelectHouseLedger failed")
+	assert.Len(t, entries, 1, "house ledger should have one entry")
+	assert.Equal(t, testTournament.ID, entries[0].TournamentID, "ledger entry tournament mismatch")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestAcquireLeaderLock(t *testing.T) {
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	leader, err := AcquireLeaderLock(db, "test", "first", time.Minute)
+	assert.NoError(t, err, "func AcquireLeaderLock failed")
+	assert.True(t, leader, "first holder should get the lock")
+	leader, err = AcquireLeaderLock(db, "test", "second", time.Minute)
+	assert.NoError(t, err, "func AcquireLeaderLock failed")
+	assert.False(t, leader, "second holder should not get a taken lock")
+	err = ReleaseLeaderLock(db, "test", "first")
+	assert.NoError(t, err, "func ReleaseLeaderLock failed")
+	leader, err = AcquireLeaderLock(db, "test", "second", time.Minute)
+	assert.NoError(t, err, "func AcquireLeaderLock failed")
+	assert.True(t, leader, "second holder should get a released lock")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
+
+func TestTournamentRegistrations(t *testing.T) {
+	var status int
+	db, err := prepareTestEnv()
+	assert.NoError(t, err, "func prepareTestEnv failed")
+	defer db.Close()
+
+	now := time.Now()
+	opens, closes, starts := now.Add(-time.Hour), now.Add(-time.Minute), now.Add(-time.Second)
+	tournament := testTournament
+	tournament.Status = entity.TournamentIsScheduled
+	tournament.RegistrationOpensAt, tournament.RegistrationClosesAt, tournament.StartsAt = &opens, &closes, &starts
+	err = AnnounceTournaments(db, tournament)
+	assert.NoError(t, err, "func AnnounceTournaments failed")
+
+	opened, err := OpenTournamentRegistrations(db, now)
+	assert.NoError(t, err, "func OpenTournamentRegistrations failed")
+	assert.Equal(t, int64(1), opened, "registration should be opened")
+	closed, err := CloseTournamentRegistrations(db, now)
+	assert.NoError(t, err, "func CloseTournamentRegistrations failed")
+	assert.Equal(t, int64(1), closed, "registration should be closed")
+	err = db.QueryRow("SELECT status FROM tournament WHERE id = $1 ", testTournament.ID).Scan(&status)
+	assert.NoError(t, err, "select tournament return error")
+	assert.Equal(t, entity.TournamentIsRegistrationClosed, status, "tournament registration should be closed")
+	ids, err := SelectTournamentsToStart(db, now)
+	assert.NoError(t, err, "func SelectTournamentsToStart failed")
+	assert.Equal(t, []int{testTournament.ID}, ids, "tournament should be ready to start")
+
+	err = dropTestSchema(db)
+	assert.NoError(t, err, "func dropTestSchema faild")
+}
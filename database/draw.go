@@ -0,0 +1,43 @@
+package database
+
+import (
+	"github.com/lib/pq"
+	"github.com/mishelini/entity"
+)
+
+// SetTournamentServerSeed store the secret server seed of the tournament draw and its published hash.
+func SetTournamentServerSeed(db Queryer, tournamentID int, seed string, hash string) error {
+	id := 0
+	err := db.QueryRow("UPDATE tournament SET server_seed = $1, server_seed_hash = $2 WHERE id = $3 RETURNING id",
+		seed, hash, tournamentID).Scan(&id)
+	return err
+}
+
+// SaveTournamentDraw store the client seed and the participant list the winner was drawn from.
+func SaveTournamentDraw(db Queryer, tournamentID int, clientSeed string, players []int) error {
+	ids := make(pq.Int64Array, len(players))
+	for i, p := range players {
+		ids[i] = int64(p)
+	}
+	id := 0
+	err := db.QueryRow("UPDATE tournament SET client_seed = $1, draw_players = $2 WHERE id = $3 RETURNING id",
+		clientSeed, ids, tournamentID).Scan(&id)
+	return err
+}
+
+// SelectTournamentDraw select draw inputs of the tournament.
+func SelectTournamentDraw(db Queryer, tournamentID int) (entity.Draw, error) {
+	var draw entity.Draw
+	var ids pq.Int64Array
+	row := db.QueryRow(`SELECT server_seed, server_seed_hash, client_seed, COALESCE(draw_players, '{}')
+		FROM tournament WHERE id = $1`, tournamentID)
+	err := row.Scan(&draw.ServerSeed, &draw.ServerSeedHash, &draw.ClientSeed, &ids)
+	if err != nil {
+		return draw, err
+	}
+	draw.Players = make([]int, len(ids))
+	for i, id := range ids {
+		draw.Players[i] = int(id)
+	}
+	return draw, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// HouseAccountID id of the default house account which collects the rake.
+const HouseAccountID = 1
+
+// SelectHouseAccount select house account by id.
+func SelectHouseAccount(db Queryer, houseID int) (entity.HouseAccount, error) {
+	var house entity.HouseAccount
+	row := db.QueryRow("SELECT id, name, balance FROM house_account WHERE id = $1 ", houseID)
+	err := row.Scan(&house.ID, &house.Name, &house.Balance)
+	return house, err
+}
+
+// ChangeHouseBalance add amount (may be negative) to the house account balance.
+func ChangeHouseBalance(db Queryer, houseID int, amount int64) error {
+	id := 0
+	err := db.QueryRow("UPDATE house_account SET balance = balance + $1 WHERE id = $2 RETURNING id", amount, houseID).Scan(&id)
+	return err
+}
+
+// InsertHouseLedgerEntry  insert new entry into house ledger.
+func InsertHouseLedgerEntry(db Queryer, entry entity.HouseLedgerEntry) error {
+	id := 0
+	err := db.QueryRow(`INSERT INTO house_ledger (house_id, tournament_id, player_id, amount, kind)
+		VALUES($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5) RETURNING id`,
+		entry.HouseID, entry.TournamentID, entry.PlayerID, entry.Amount, entry.Kind).Scan(&id)
+	return err
+}
+
+// SelectHouseLedger select house ledger entries by house id, newest first.
+func SelectHouseLedger(db Queryer, houseID int) ([]entity.HouseLedgerEntry, error) {
+	entries := make([]entity.HouseLedgerEntry, 0)
+	rows, err := db.Query(`SELECT id, house_id, COALESCE(tournament_id, 0), COALESCE(player_id, 0), amount, kind, created_at
+		FROM house_ledger WHERE house_id = $1 ORDER BY id DESC`, houseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entity.HouseLedgerEntry
+		if err := rows.Scan(&e.ID, &e.HouseID, &e.TournamentID, &e.PlayerID, &e.Amount, &e.Kind, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
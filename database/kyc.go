@@ -0,0 +1,45 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// InsertKYCDocument insert reference to a verification document of the player.
+func InsertKYCDocument(db Queryer, doc entity.KYCDocument) error {
+	_, err := db.Exec("INSERT INTO kyc_document (player_id, kind, reference) VALUES($1, $2, $3)", doc.PlayerID, doc.Kind, doc.Reference)
+	return err
+}
+
+// SelectKYCDocuments select verification documents of the player, oldest first.
+func SelectKYCDocuments(db Queryer, playerID int) ([]entity.KYCDocument, error) {
+	docs := make([]entity.KYCDocument, 0)
+	rows, err := db.Query("SELECT id, player_id, kind, reference, created_at FROM kyc_document WHERE player_id = $1 ORDER BY id", playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d entity.KYCDocument
+		if err := rows.Scan(&d.ID, &d.PlayerID, &d.Kind, &d.Reference, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// SetPlayerKYCStatus change the verification status of the player, an empty reviewer keeps the last review.
+func SetPlayerKYCStatus(db Queryer, playerID int, status string, note string, reviewer string) error {
+	id := 0
+	if reviewer == "" {
+		return db.QueryRow("UPDATE player SET kyc_status = $2 WHERE id = $1 RETURNING id", playerID, status).Scan(&id)
+	}
+	return db.QueryRow(`UPDATE player SET kyc_status = $2, kyc_note = $3, kyc_reviewer = $4, kyc_reviewed_at = now()
+		WHERE id = $1 RETURNING id`, playerID, status, note, reviewer).Scan(&id)
+}
+
+// InsertPlayerWithdrawal record withdrawal from the player account.
+func InsertPlayerWithdrawal(db Queryer, playerID int, amount int64) error {
+	_, err := db.Exec("INSERT INTO player_withdrawal (player_id, amount) VALUES($1, $2)", playerID, amount)
+	return err
+}
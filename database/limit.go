@@ -0,0 +1,65 @@
+package database
+
+import (
+	"time"
+
+	"github.com/mishelini/entity"
+)
+
+// SelectPlayerLimits select responsible-gaming limits of the player.
+func SelectPlayerLimits(db Queryer, playerID int) ([]entity.PlayerLimit, error) {
+	limits := make([]entity.PlayerLimit, 0)
+	rows, err := db.Query(`SELECT player_id, kind, period, amount, pending_amount, pending_from
+		FROM player_limit WHERE player_id = $1 ORDER BY kind, period`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l entity.PlayerLimit
+		if err := rows.Scan(&l.PlayerID, &l.Kind, &l.Period, &l.Amount, &l.PendingAmount, &l.PendingFrom); err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
+
+// SavePlayerLimit insert or replace the responsible-gaming limit.
+func SavePlayerLimit(db Queryer, limit entity.PlayerLimit) error {
+	_, err := db.Exec(`INSERT INTO player_limit (player_id, kind, period, amount, pending_amount, pending_from)
+		VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (player_id, kind, period)
+		DO UPDATE SET amount = $4, pending_amount = $5, pending_from = $6`,
+		limit.PlayerID, limit.Kind, limit.Period, limit.Amount, limit.PendingAmount, limit.PendingFrom)
+	return err
+}
+
+// DeletePlayerLimit delete the responsible-gaming limit.
+func DeletePlayerLimit(db Queryer, playerID int, kind string, period string) error {
+	_, err := db.Exec("DELETE FROM player_limit WHERE player_id = $1 AND kind = $2 AND period = $3", playerID, kind, period)
+	return err
+}
+
+// InsertPlayerDeposit record funding of the player account.
+func InsertPlayerDeposit(db Queryer, playerID int, amount int64) error {
+	_, err := db.Exec("INSERT INTO player_deposit (player_id, amount) VALUES($1, $2)", playerID, amount)
+	return err
+}
+
+// SumPlayerDeposits sum funding of the player account since the time.
+func SumPlayerDeposits(db Queryer, playerID int, since time.Time) (int64, error) {
+	var sum int64
+	err := db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM player_deposit WHERE player_id = $1 AND created_at >= $2`,
+		playerID, since).Scan(&sum)
+	return sum, err
+}
+
+// SumPlayerSpend sum tournament purchases of the player since the time less the refunds. Entries paid
+// with tickets are not counted, the player spent the money in the satellite.
+func SumPlayerSpend(db Queryer, playerID int, since time.Time) (int64, error) {
+	var sum int64
+	err := db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM tournament_purchase
+		WHERE player_id = $1 AND created_at >= $2 AND kind <> $3`,
+		playerID, since, entity.PurchaseTicketEntry).Scan(&sum)
+	return sum, err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+const matchColumns = `tournament_id, number, bracket, round, position, player1, player2, score1, score2, winner, status,
+	filled, winner_to, winner_slot, loser_to, loser_slot`
+
+// InsertMatch  insert new tournament match.
+func InsertMatch(db Queryer, m entity.Match) error {
+	number := 0
+	err := db.QueryRow(`INSERT INTO tournament_match (`+matchColumns+`)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING number`,
+		m.TournamentID, m.Number, m.Bracket, m.Round, m.Position, m.Player1, m.Player2, m.Score1, m.Score2, m.Winner, m.Status,
+		m.Filled, m.WinnerTo, m.WinnerSlot, m.LoserTo, m.LoserSlot).Scan(&number)
+	return err
+}
+
+// UpdateMatch update players, result and status of the tournament match.
+func UpdateMatch(db Queryer, m entity.Match) error {
+	number := 0
+	err := db.QueryRow(`UPDATE tournament_match SET player1 = $1, player2 = $2, score1 = $3, score2 = $4, winner = $5,
+		status = $6, filled = $7 WHERE tournament_id = $8 AND number = $9 RETURNING number`,
+		m.Player1, m.Player2, m.Score1, m.Score2, m.Winner, m.Status, m.Filled, m.TournamentID, m.Number).Scan(&number)
+	return err
+}
+
+// SelectMatches select tournament matches ordered by number.
+func SelectMatches(db Queryer, tournamentID int) ([]entity.Match, error) {
+	matches := make([]entity.Match, 0)
+	rows, err := db.Query(`SELECT `+matchColumns+` FROM tournament_match WHERE tournament_id = $1 ORDER BY number`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m entity.Match
+		err := rows.Scan(&m.TournamentID, &m.Number, &m.Bracket, &m.Round, &m.Position, &m.Player1, &m.Player2,
+			&m.Score1, &m.Score2, &m.Winner, &m.Status, &m.Filled, &m.WinnerTo, &m.WinnerSlot, &m.LoserTo, &m.LoserSlot)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// CountMatches count tournament matches.
+func CountMatches(db Queryer, tournamentID int) (int, error) {
+	count := 0
+	err := db.QueryRow("SELECT count(*) FROM tournament_match WHERE tournament_id = $1", tournamentID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"time"
+)
+
+// InsertRequestNonce store the nonce of a signed request, it returns false when the nonce was already used.
+func InsertRequestNonce(db Queryer, integration string, nonce string, expiresAt time.Time) (bool, error) {
+	res, err := db.Exec(`INSERT INTO request_nonce (integration, nonce, expires_at) VALUES($1, $2, $3)
+		ON CONFLICT DO NOTHING`, integration, nonce, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteExpiredRequestNonces delete nonces whose requests can't be replayed any more.
+func DeleteExpiredRequestNonces(db Queryer, now time.Time) error {
+	_, err := db.Exec("DELETE FROM request_nonce WHERE expires_at < $1", now)
+	return err
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// AddTournamentPurchase add a re-entry or an add-on to the tournament player, the paid amount and the rake
+// are added to the totals of the player.
+func AddTournamentPurchase(db Queryer, tournamentID int, playerID int, kind string, paid int64, rake int64) (entity.TournamentPlayer, error) {
+	entries, addOns := 0, 0
+	if kind == entity.PurchaseReEntry {
+		entries = 1
+	} else {
+		addOns = 1
+	}
+	var player entity.TournamentPlayer
+	err := db.QueryRow(`UPDATE tournament_player SET paid = paid + $3, rake = rake + $4, entries = entries + $5, add_ons = add_ons + $6
+		WHERE tournament_id = $1 AND player_id = $2 RETURNING player_id, tournament_id, paid, rake, entries, add_ons`,
+		tournamentID, playerID, paid, rake, entries, addOns).Scan(&player.PlayerID, &player.TournamentID,
+		&player.Paid, &player.Rake, &player.Entries, &player.AddOns)
+	return player, err
+}
+
+// InsertPurchase record a purchase in the tournament purchase ledger.
+func InsertPurchase(db Queryer, purchase entity.Purchase) error {
+	_, err := db.Exec("INSERT INTO tournament_purchase (tournament_id, player_id, kind, amount, rake) VALUES($1, $2, $3, $4, $5)",
+		purchase.TournamentID, purchase.PlayerID, purchase.Kind, purchase.Amount, purchase.Rake)
+	return err
+}
+
+// SelectPurchases select the purchase ledger of the tournament, oldest first.
+func SelectPurchases(db Queryer, tournamentID int) ([]entity.Purchase, error) {
+	purchases := make([]entity.Purchase, 0)
+	rows, err := db.Query(`SELECT id, tournament_id, player_id, kind, amount, rake, created_at FROM tournament_purchase
+		WHERE tournament_id = $1 ORDER BY id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p entity.Purchase
+		if err := rows.Scan(&p.ID, &p.TournamentID, &p.PlayerID, &p.Kind, &p.Amount, &p.Rake, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, p)
+	}
+	return purchases, rows.Err()
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// InsertTournamentResult  insert final standing of the player into tournament_result table.
+func InsertTournamentResult(db Queryer, tournamentID int, standing entity.Standing) error {
+	player_id := 0
+	err := db.QueryRow(`INSERT INTO tournament_result (tournament_id, player_id, place, score, prize)
+		VALUES($1, $2, $3, $4, $5) RETURNING player_id`,
+		tournamentID, standing.PlayerID, standing.Place, standing.Score, standing.Prize).Scan(&player_id)
+	return err
+}
+
+// SelectTournamentResults select final standings of the tournament ordered by place.
+func SelectTournamentResults(db Queryer, tournamentID int) ([]entity.Standing, error) {
+	standings := make([]entity.Standing, 0)
+	rows, err := db.Query(`SELECT player_id, place, COALESCE(score, 0), prize FROM tournament_result
+		WHERE tournament_id = $1 ORDER BY place, player_id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s entity.Standing
+		if err := rows.Scan(&s.PlayerID, &s.Place, &s.Score, &s.Prize); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	return standings, rows.Err()
+}
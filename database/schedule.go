@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mishelini/entity"
+)
+
+// AcquireLeaderLock takes or extends the named lock for holder until now + ttl.
+// It returns false when another holder owns a lock which has not expired yet.
+func AcquireLeaderLock(db Queryer, name string, holder string, ttl time.Duration) (bool, error) {
+	owner := ""
+	err := db.QueryRow(`INSERT INTO scheduler_lock (name, holder, expires_at) VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE scheduler_lock.holder = EXCLUDED.holder OR scheduler_lock.expires_at < now()
+		RETURNING holder`, name, holder, ttl.Milliseconds()).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLeaderLock drops the named lock if it is owned by holder.
+func ReleaseLeaderLock(db Queryer, name string, holder string) error {
+	_, err := db.Exec("DELETE FROM scheduler_lock WHERE name = $1 AND holder = $2", name, holder)
+	return err
+}
+
+// OpenTournamentRegistrations moves scheduled tournaments whose registration time has come to announced.
+func OpenTournamentRegistrations(db Queryer, now time.Time) (int64, error) {
+	res, err := db.Exec(`UPDATE tournament SET status = $1 WHERE status = $2 AND registration_opens_at <= $3`,
+		entity.TournamentIsAnnounced, entity.TournamentIsScheduled, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CloseTournamentRegistrations closes registration of announced tournaments whose registration time is over.
+func CloseTournamentRegistrations(db Queryer, now time.Time) (int64, error) {
+	res, err := db.Exec(`UPDATE tournament SET status = $1 WHERE status = $2 AND registration_closes_at <= $3`,
+		entity.TournamentIsRegistrationClosed, entity.TournamentIsAnnounced, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SelectTournamentsToStart select ids of not yet started tournaments whose start time has come.
+func SelectTournamentsToStart(db Queryer, now time.Time) ([]int, error) {
+	ids := make([]int, 0)
+	rows, err := db.Query(`SELECT id FROM tournament WHERE status IN ($1, $2) AND starts_at <= $3 ORDER BY starts_at`,
+		entity.TournamentIsAnnounced, entity.TournamentIsRegistrationClosed, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// InsertScore store a score submitted by the player.
+func InsertScore(db Queryer, tournamentID int, playerID int, score float64) error {
+	_, err := db.Exec("INSERT INTO tournament_score (tournament_id, player_id, score) VALUES($1, $2, $3)",
+		tournamentID, playerID, score)
+	return err
+}
+
+// SelectLeaderboard select the scores of the tournament aggregated per player, best first.
+// In sum mode all scores of the player are added up, otherwise the best one counts.
+func SelectLeaderboard(db Queryer, tournamentID int, mode string) ([]entity.LeaderboardEntry, error) {
+	aggregate := "max(score)"
+	if mode == entity.ScoreModeSum {
+		aggregate = "sum(score)"
+	}
+	entries := make([]entity.LeaderboardEntry, 0)
+	rows, err := db.Query(`SELECT player_id, `+aggregate+`, count(*), max(created_at) FROM tournament_score
+		WHERE tournament_id = $1 GROUP BY player_id ORDER BY 2 DESC, player_id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entity.LeaderboardEntry
+		if err := rows.Scan(&e.PlayerID, &e.Score, &e.Submissions, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
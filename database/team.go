@@ -0,0 +1,146 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+// InsertTeam insert new team and return its id.
+func InsertTeam(db Queryer, name string, captainID int) (int, error) {
+	id := 0
+	err := db.QueryRow("INSERT INTO team (name, captain_id) VALUES($1, $2) RETURNING id", name, captainID).Scan(&id)
+	return id, err
+}
+
+// InsertTeamMember add player to the team.
+func InsertTeamMember(db Queryer, member entity.TeamMember) error {
+	_, err := db.Exec("INSERT INTO team_member (team_id, player_id, share, accepted) VALUES($1, $2, $3, $4)",
+		member.TeamID, member.PlayerID, member.Share, member.Accepted)
+	return err
+}
+
+// AcceptTeamMember put the invited player on the roster, it returns false when there is no pending invite.
+func AcceptTeamMember(db Queryer, teamID int, playerID int) (bool, error) {
+	res, err := db.Exec("UPDATE team_member SET accepted = true WHERE team_id = $1 AND player_id = $2 AND NOT accepted",
+		teamID, playerID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SelectTeam select team by id.
+func SelectTeam(db Queryer, teamID int) (entity.Team, error) {
+	var team entity.Team
+	err := db.QueryRow("SELECT id, name, captain_id FROM team WHERE id = $1", teamID).Scan(&team.ID, &team.Name, &team.CaptainID)
+	return team, err
+}
+
+// SelectTeamForUpdate select team by id and lock it until the end of the transaction.
+func SelectTeamForUpdate(db Queryer, teamID int) (entity.Team, error) {
+	var team entity.Team
+	err := db.QueryRow("SELECT id, name, captain_id FROM team WHERE id = $1 FOR UPDATE", teamID).Scan(&team.ID, &team.Name, &team.CaptainID)
+	return team, err
+}
+
+// SelectTeamMembers select the roster of the team ordered by player id.
+func SelectTeamMembers(db Queryer, teamID int) ([]entity.TeamMember, error) {
+	return selectTeamMembers(db, teamID, true)
+}
+
+// SelectTeamInvites select the invited players who have not accepted yet ordered by player id.
+func SelectTeamInvites(db Queryer, teamID int) ([]entity.TeamMember, error) {
+	return selectTeamMembers(db, teamID, false)
+}
+
+func selectTeamMembers(db Queryer, teamID int, accepted bool) ([]entity.TeamMember, error) {
+	members := make([]entity.TeamMember, 0)
+	rows, err := db.Query(`SELECT team_id, player_id, share, accepted FROM team_member
+		WHERE team_id = $1 AND accepted = $2 ORDER BY player_id`, teamID, accepted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m entity.TeamMember
+		if err := rows.Scan(&m.TeamID, &m.PlayerID, &m.Share, &m.Accepted); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// CountOpenTeamEntries count the tournaments the team is entered in which are neither finished nor cancelled.
+func CountOpenTeamEntries(db Queryer, teamID int) (int, error) {
+	count := 0
+	err := db.QueryRow(`SELECT count(*) FROM tournament_team tt JOIN tournament t ON t.id = tt.tournament_id
+		WHERE tt.team_id = $1 AND t.status NOT IN ($2, $3)`,
+		teamID, entity.TournamentIsFinished, entity.TournamentIsCancelled).Scan(&count)
+	return count, err
+}
+
+// InsertTournamentTeam register team in the tournament.
+func InsertTournamentTeam(db Queryer, tournamentID int, teamID int) error {
+	_, err := db.Exec("INSERT INTO tournament_team (tournament_id, team_id) VALUES($1, $2)", tournamentID, teamID)
+	return err
+}
+
+// InsertTournamentTeamMember store the member and his share as they were when the team entered the tournament.
+func InsertTournamentTeamMember(db Queryer, tournamentID int, member entity.TeamMember) error {
+	_, err := db.Exec("INSERT INTO tournament_team_member (tournament_id, team_id, player_id, share) VALUES($1, $2, $3, $4)",
+		tournamentID, member.TeamID, member.PlayerID, member.Share)
+	return err
+}
+
+// SelectTournamentTeamMembers select the roster the team entered the tournament with ordered by player id.
+func SelectTournamentTeamMembers(db Queryer, tournamentID int, teamID int) ([]entity.TeamMember, error) {
+	members := make([]entity.TeamMember, 0)
+	rows, err := db.Query(`SELECT team_id, player_id, share FROM tournament_team_member
+		WHERE tournament_id = $1 AND team_id = $2 ORDER BY player_id`, tournamentID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m := entity.TeamMember{Accepted: true}
+		if err := rows.Scan(&m.TeamID, &m.PlayerID, &m.Share); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SelectTournamentTeams select teams of the tournament.
+func SelectTournamentTeams(db Queryer, tournamentID int) ([]entity.TournamentTeam, error) {
+	teams := make([]entity.TournamentTeam, 0)
+	rows, err := db.Query(`SELECT tournament_id, team_id, place, prize FROM tournament_team
+		WHERE tournament_id = $1 ORDER BY team_id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t entity.TournamentTeam
+		if err := rows.Scan(&t.TournamentID, &t.TeamID, &t.Place, &t.Prize); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// CountTournamentTeams count teams registered in the tournament.
+func CountTournamentTeams(db Queryer, tournamentID int) (int, error) {
+	count := 0
+	err := db.QueryRow("SELECT count(*) FROM tournament_team WHERE tournament_id = $1", tournamentID).Scan(&count)
+	return count, err
+}
+
+// UpdateTournamentTeamResult store the final place and prize of the team.
+func UpdateTournamentTeamResult(db Queryer, tournamentID int, teamID int, place int, prize int64) error {
+	_, err := db.Exec("UPDATE tournament_team SET place = $3, prize = $4 WHERE tournament_id = $1 AND team_id = $2",
+		tournamentID, teamID, place, prize)
+	return err
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mishelini/entity"
+)
+
+const templateColumns = `id, name, settings, recurrence, lead_minutes, active, next_start_at, last_tournament_id`
+
+func scanTemplate(row scanner) (entity.Template, error) {
+	var t entity.Template
+	var settings []byte
+	err := row.Scan(&t.ID, &t.Name, &settings, &t.Recurrence, &t.LeadMinutes, &t.Active, &t.NextStartAt, &t.LastTournamentID)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(settings, &t.Params)
+	return t, err
+}
+
+// InsertTemplate insert new tournament template and return its id.
+func InsertTemplate(db Queryer, template entity.Template) (int, error) {
+	settings, err := json.Marshal(template.Params)
+	if err != nil {
+		return 0, err
+	}
+	id := 0
+	err = db.QueryRow(`INSERT INTO tournament_template (name, settings, recurrence, lead_minutes, active, next_start_at)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		template.Name, settings, template.Recurrence, template.LeadMinutes, template.Active, template.NextStartAt).Scan(&id)
+	return id, err
+}
+
+// UpdateTemplate replace the settings and the recurrence of the template.
+func UpdateTemplate(db Queryer, template entity.Template) error {
+	settings, err := json.Marshal(template.Params)
+	if err != nil {
+		return err
+	}
+	id := 0
+	return db.QueryRow(`UPDATE tournament_template SET name = $2, settings = $3, recurrence = $4, lead_minutes = $5,
+		active = $6, next_start_at = $7 WHERE id = $1 RETURNING id`,
+		template.ID, template.Name, settings, template.Recurrence, template.LeadMinutes, template.Active, template.NextStartAt).Scan(&id)
+}
+
+// SelectTemplate select template by id.
+func SelectTemplate(db Queryer, templateID int) (entity.Template, error) {
+	return scanTemplate(db.QueryRow("SELECT "+templateColumns+" FROM tournament_template WHERE id = $1", templateID))
+}
+
+// SelectTemplateForUpdate select template by id and lock it until the end of the transaction.
+func SelectTemplateForUpdate(db Queryer, templateID int) (entity.Template, error) {
+	return scanTemplate(db.QueryRow("SELECT "+templateColumns+" FROM tournament_template WHERE id = $1 FOR UPDATE", templateID))
+}
+
+// SelectTemplates select all templates ordered by id.
+func SelectTemplates(db Queryer) ([]entity.Template, error) {
+	templates := make([]entity.Template, 0)
+	rows, err := db.Query("SELECT " + templateColumns + " FROM tournament_template ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// SelectDueTemplates select active templates whose next tournament has to be announced by now.
+func SelectDueTemplates(db Queryer, now time.Time) ([]int, error) {
+	ids := make([]int, 0)
+	rows, err := db.Query(`SELECT id FROM tournament_template WHERE active AND next_start_at IS NOT NULL
+		AND next_start_at - lead_minutes * interval '1 minute' <= $1 ORDER BY id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetTemplateNextStart move the template to its next start time, lastTournamentID 0 keeps the last tournament.
+func SetTemplateNextStart(db Queryer, templateID int, next *time.Time, lastTournamentID int) error {
+	_, err := db.Exec(`UPDATE tournament_template SET next_start_at = $2,
+		last_tournament_id = CASE WHEN $3 = 0 THEN last_tournament_id ELSE $3 END WHERE id = $1`,
+		templateID, next, lastTournamentID)
+	return err
+}
+
+// DeleteTemplate delete template by id, it returns false when there is no such template.
+func DeleteTemplate(db Queryer, templateID int) (bool, error) {
+	res, err := db.Exec("DELETE FROM tournament_template WHERE id = $1", templateID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// NextTournamentID takes the next id from the tournament id sequence, concurrent callers never get the same id.
+func NextTournamentID(db Queryer) (int, error) {
+	id := 0
+	err := db.QueryRow("SELECT nextval(pg_get_serial_sequence('tournament', 'id'))").Scan(&id)
+	return id, err
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"github.com/mishelini/entity"
+)
+
+const ticketColumns = `id, token, player_id, source_tournament_id, tournament_id, value, unused, status, created_at, settled_at`
+
+func scanTicket(row scanner) (entity.Ticket, error) {
+	var t entity.Ticket
+	err := row.Scan(&t.ID, &t.Token, &t.PlayerID, &t.SourceTournamentID, &t.TournamentID, &t.Value, &t.Unused,
+		&t.Status, &t.CreatedAt, &t.SettledAt)
+	return t, err
+}
+
+// InsertTicket issue a ticket and return its id.
+func InsertTicket(db Queryer, ticket entity.Ticket) (int, error) {
+	id := 0
+	err := db.QueryRow(`INSERT INTO ticket (token, player_id, source_tournament_id, tournament_id, value, unused)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		ticket.Token, ticket.PlayerID, ticket.SourceTournamentID, ticket.TournamentID, ticket.Value, ticket.Unused).Scan(&id)
+	return id, err
+}
+
+// SelectTicketForUpdate select ticket by token and lock it until the end of the transaction.
+func SelectTicketForUpdate(db Queryer, token string) (entity.Ticket, error) {
+	return scanTicket(db.QueryRow("SELECT "+ticketColumns+" FROM ticket WHERE token = $1 FOR UPDATE", token))
+}
+
+// SelectTicketByIDForUpdate select ticket by id and lock it until the end of the transaction.
+func SelectTicketByIDForUpdate(db Queryer, ticketID int) (entity.Ticket, error) {
+	return scanTicket(db.QueryRow("SELECT "+ticketColumns+" FROM ticket WHERE id = $1 FOR UPDATE", ticketID))
+}
+
+// SelectPlayerTickets select tickets of the player, newest first.
+func SelectPlayerTickets(db Queryer, playerID int) ([]entity.Ticket, error) {
+	return selectTickets(db, "SELECT "+ticketColumns+" FROM ticket WHERE player_id = $1 ORDER BY id DESC", playerID)
+}
+
+// SelectIssuedTickets select tickets into the tournament which have not been used yet.
+func SelectIssuedTickets(db Queryer, tournamentID int) ([]entity.Ticket, error) {
+	return selectTickets(db, "SELECT "+ticketColumns+" FROM ticket WHERE tournament_id = $1 AND status = $2 ORDER BY id FOR UPDATE",
+		tournamentID, entity.TicketIssued)
+}
+
+func selectTickets(db Queryer, query string, args ...interface{}) ([]entity.Ticket, error) {
+	tickets := make([]entity.Ticket, 0)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// ChangeTicketStatus set ticket status, tickets which are used, expired or cashed get their settle time.
+func ChangeTicketStatus(db Queryer, ticketID int, status string) error {
+	_, err := db.Exec(`UPDATE ticket SET status = $2, settled_at = CASE WHEN $2 = 'issued' THEN NULL ELSE now() END
+		WHERE id = $1`, ticketID, status)
+	return err
+}
+
+// SetTournamentUserTicket remember the ticket the player entered the tournament with.
+func SetTournamentUserTicket(db Queryer, tournamentID int, playerID int, ticketID int) error {
+	_, err := db.Exec("UPDATE tournament_player SET ticket_id = $3 WHERE tournament_id = $1 AND player_id = $2",
+		tournamentID, playerID, ticketID)
+	return err
+}
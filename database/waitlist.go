@@ -0,0 +1,57 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/mishelini/entity"
+)
+
+// InsertWaitlistEntry put player at the end of the tournament waitlist and return his position.
+func InsertWaitlistEntry(db Queryer, tournamentID int, playerID int) (int, error) {
+	id := 0
+	err := db.QueryRow("INSERT INTO tournament_waitlist (tournament_id, player_id) VALUES($1, $2) RETURNING id",
+		tournamentID, playerID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	position := 0
+	err = db.QueryRow("SELECT count(*) FROM tournament_waitlist WHERE tournament_id = $1 AND id <= $2", tournamentID, id).Scan(&position)
+	return position, err
+}
+
+// SelectWaitlist select tournament waitlist in FIFO order.
+func SelectWaitlist(db Queryer, tournamentID int) ([]entity.WaitlistEntry, error) {
+	entries := make([]entity.WaitlistEntry, 0)
+	rows, err := db.Query(`SELECT id, tournament_id, player_id, created_at FROM tournament_waitlist
+		WHERE tournament_id = $1 ORDER BY id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entity.WaitlistEntry
+		if err := rows.Scan(&e.ID, &e.TournamentID, &e.PlayerID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteWaitlistEntry remove player from the tournament waitlist,
+// it returns false when the player was not waitlisted.
+func DeleteWaitlistEntry(db Queryer, tournamentID int, playerID int) (bool, error) {
+	id := 0
+	err := db.QueryRow("DELETE FROM tournament_waitlist WHERE tournament_id = $1 AND player_id = $2 RETURNING id",
+		tournamentID, playerID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClearWaitlist remove all players from the tournament waitlist.
+func ClearWaitlist(db Queryer, tournamentID int) error {
+	_, err := db.Exec("DELETE FROM tournament_waitlist WHERE tournament_id = $1", tournamentID)
+	return err
+}
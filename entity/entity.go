@@ -1,7 +1,9 @@
 package entity
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 )
 
 //Params application parameters
@@ -16,6 +18,47 @@ type Params struct {
 	SSLMode  string `json:"ssl_mode" yaml:"ssl_mode"`
 	LogFile  string `json:"log_file" yaml:"log_file"`
 	InitData bool   `json:"init_data" yaml:"init_data"`
+	// SchedulerInterval seconds between scheduler runs
+	SchedulerInterval int `json:"scheduler_interval" yaml:"scheduler_interval"`
+	// JWT keys: HS256 secret and RS256 public key files, empty files disable the algorithm
+	JWTSecretFile    string `json:"jwt_secret_file" yaml:"jwt_secret_file"`
+	JWTPublicKeyFile string `json:"jwt_public_key_file" yaml:"jwt_public_key_file"`
+	JWTIssuer        string `json:"jwt_issuer" yaml:"jwt_issuer"`
+	JWTAudience      string `json:"jwt_audience" yaml:"jwt_audience"`
+	// SigningSecretsFile file with the HMAC secrets of the game-server integrations, empty disables request signing
+	SigningSecretsFile string `json:"signing_secrets_file" yaml:"signing_secrets_file"`
+	// SignatureSkew seconds a signed request timestamp may differ from the server clock
+	SignatureSkew int `json:"signature_skew" yaml:"signature_skew"`
+	// RateLimits request rates per client IP, API key and player
+	RateLimits RateLimits `json:"rate_limits" yaml:"rate_limits"`
+	// TLS certificate and key files, empty files serve plain HTTP. Rotated files are reloaded.
+	TLSCertFile string `json:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file" yaml:"tls_key_file"`
+	// TLSClientCAFile CA bundle verifying client certificates, empty disables mutual TLS
+	TLSClientCAFile string `json:"tls_client_ca_file" yaml:"tls_client_ca_file"`
+	// TLSRequireClientCert rejects connections without a client certificate
+	TLSRequireClientCert bool `json:"tls_require_client_cert" yaml:"tls_require_client_cert"`
+	// TLSClientRoles roles of the services by the common name of their client certificate
+	TLSClientRoles map[string]string `json:"tls_client_roles" yaml:"tls_client_roles"`
+	// LimitCoolingOffHours delay before a raised responsible-gaming limit takes effect
+	LimitCoolingOffHours int `json:"limit_cooling_off_hours" yaml:"limit_cooling_off_hours"`
+	// SuspendedEntryPolicy what happens to open tournament entries of a suspended player: keep or refund
+	SuspendedEntryPolicy string `json:"suspended_entry_policy" yaml:"suspended_entry_policy"`
+	// KYCDepositThreshold tournaments with a higher deposit are open only to verified players, zero disables the check
+	KYCDepositThreshold float64 `json:"kyc_deposit_threshold" yaml:"kyc_deposit_threshold"`
+}
+
+// RateLimit token bucket refilled with Rate requests per second holding up to Burst requests, zero rate disables the limit
+type RateLimit struct {
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+// RateLimits request rate limits of the API
+type RateLimits struct {
+	IP     RateLimit `json:"ip" yaml:"ip"`
+	APIKey RateLimit `json:"api_key" yaml:"api_key"`
+	Player RateLimit `json:"player" yaml:"player"`
 }
 
 func (p *Params) Validate() error {
@@ -43,32 +86,332 @@ func (p *Params) Validate() error {
 	if p.LogFile == "" {
 		return fmt.Errorf("invalid logfilename")
 	}
+	switch p.SuspendedEntryPolicy {
+	case "", EntryPolicyKeep, EntryPolicyRefund:
+	default:
+		return fmt.Errorf("invalid suspended entry policy %q", p.SuspendedEntryPolicy)
+	}
+	if p.KYCDepositThreshold < 0 {
+		return fmt.Errorf("invalid kyc deposit threshold")
+	}
+	if (p.TLSCertFile == "") != (p.TLSKeyFile == "") {
+		return fmt.Errorf("invalid tls: both certificate and key are needed")
+	}
+	if p.TLSClientCAFile != "" && p.TLSCertFile == "" {
+		return fmt.Errorf("invalid tls: client certificates need a server certificate")
+	}
+	for cn, role := range p.TLSClientRoles {
+		switch role {
+		case RoleAdmin, RoleOperator, RoleSupport, RoleGameServer:
+		default:
+			return fmt.Errorf("invalid tls client role %q of %s", role, cn)
+		}
+	}
 	return nil
 }
 
 // TournamentIsFinished bool value to add test data
 var TournamentIsFinished = 1
 
+// Other tournament statuses, announced tournaments accept new players.
+var (
+	TournamentIsAnnounced = 0
+	TournamentIsRunning   = 2
+	TournamentIsCancelled = 3
+	// TournamentIsScheduled registration is not open yet
+	TournamentIsScheduled = 4
+	// TournamentIsRegistrationClosed registration is closed, waiting for start
+	TournamentIsRegistrationClosed = 5
+)
+
+// TournamentStatusName human readable tournament status.
+func TournamentStatusName(status int) string {
+	switch status {
+	case TournamentIsAnnounced:
+		return "announced"
+	case TournamentIsFinished:
+		return "finished"
+	case TournamentIsRunning:
+		return "running"
+	case TournamentIsCancelled:
+		return "cancelled"
+	case TournamentIsScheduled:
+		return "scheduled"
+	case TournamentIsRegistrationClosed:
+		return "registration closed"
+	}
+	return "unknown"
+}
+
 // Player system user
 type Player struct {
 	ID        int
 	FirstName string
 	Points    int64
+	// ExcludedUntil end of the self-exclusion of the player, nil for an exclusion without end
+	ExcludedUntil   *time.Time
+	Status          string
+	StatusReason    string
+	StatusChangedAt *time.Time
+	KYCStatus       string
+	KYCNote         string
+	KYCReviewedAt   *time.Time
+	KYCReviewer     string
 }
 
 // Tournament - competition events
 type Tournament struct {
+	ID          int
+	Deposit     int64
+	Prize       int64
+	Winner      int
+	Status      int
+	RakePercent float64
+	RakeFixed   int64
+	Guarantee   int64
+	Overlay     int64
+	MaxPlayers  int
+	MinPlayers  int
+	// Schedule, nil values mean not scheduled
+	RegistrationOpensAt  *time.Time
+	RegistrationClosesAt *time.Time
+	StartsAt             *time.Time
+	// Format decides how the winners are chosen
+	Format string
+	// Payouts prize percent per place, first place first
+	Payouts []float64
+	// ServerSeedHash published commitment to the raffle draw seed
+	ServerSeedHash string
+	// SwissRounds number of Swiss rounds, 0 means enough rounds to find a single winner
+	SwissRounds int
+	// TeamMode teams instead of single players take part, TeamFee decides who pays the deposit
+	TeamMode bool
+	TeamFee  string
+	// ScoreMode how the submitted scores of a leaderboard tournament add up
+	ScoreMode string
+	// MaxReEntries how many times a player may pay the deposit again
+	MaxReEntries int
+	// AddOnPrice price of an add-on, 0 means no add-ons; MaxAddOns 0 means no limit per player
+	AddOnPrice int64
+	MaxAddOns  int
+	// AddOnClosesAt end of the add-on window, nil means until the tournament finishes
+	AddOnClosesAt *time.Time
+	// SatelliteFor id of the tournament whose entry tickets are the prize, 0 means a cash prize.
+	// SatelliteTickets is the number of tickets, TicketUnused what happens to unused tickets.
+	SatelliteFor     int
+	SatelliteTickets int
+	TicketUnused     string
+}
+
+// Unused ticket policies, they are applied when the target tournament starts
+const (
+	// TicketExpire unused tickets expire, their value goes to the house
+	TicketExpire = "expire"
+	// TicketCash unused tickets are paid out to their holders
+	TicketCash = "cash"
+)
+
+// Ticket statuses
+const (
+	TicketIssued  = "issued"
+	TicketUsed    = "used"
+	TicketExpired = "expired"
+	TicketCashed  = "cashed"
+)
+
+// Ticket entry into a tournament won in a satellite, Value is the deposit it replaces
+type Ticket struct {
+	ID                 int
+	Token              string
+	PlayerID           int
+	SourceTournamentID int
+	TournamentID       int
+	Value              int64
+	Unused             string
+	Status             string
+	CreatedAt          time.Time
+	SettledAt          *time.Time
+}
+
+// Score modes of leaderboard tournaments
+const (
+	// ScoreModeBest the best submitted score counts
+	ScoreModeBest = "best"
+	// ScoreModeSum all submitted scores are added up
+	ScoreModeSum = "sum"
+)
+
+// Team deposit modes
+const (
+	// TeamFeeCaptain the captain pays the whole deposit
+	TeamFeeCaptain = "captain"
+	// TeamFeeSplit the deposit is split equally across the members
+	TeamFeeSplit = "split"
+)
+
+// Team players competing together under the lead of the captain
+type Team struct {
+	ID        int
+	Name      string
+	CaptainID int
+}
+
+// TeamMember player of a team, Share is his weight when the team prize is divided.
+// A member invited by the captain is on the roster only after he accepted.
+type TeamMember struct {
+	TeamID   int
+	PlayerID int
+	Share    float64
+	Accepted bool
+}
+
+// TournamentTeam team taking part in a tournament with its final place and prize
+type TournamentTeam struct {
+	TournamentID int
+	TeamID       int
+	Place        int
+	Prize        int64
+}
+
+// Tournament formats
+const (
+	// FormatRaffle the winner is drawn at random from the participants
+	FormatRaffle = "raffle"
+	// FormatRanked the game server posts a ranking or scores per player
+	FormatRanked = "ranked"
+	// FormatSingleElimination players are knocked out after the first lost match
+	FormatSingleElimination = "single_elimination"
+	// FormatDoubleElimination players are knocked out after the second lost match
+	FormatDoubleElimination = "double_elimination"
+	// FormatRoundRobin every player meets every other player
+	FormatRoundRobin = "round_robin"
+	// FormatSwiss players with similar scores are paired for a fixed number of rounds
+	FormatSwiss = "swiss"
+	// FormatLeaderboard players submit scores while the tournament is running
+	FormatLeaderboard = "leaderboard"
+)
+
+// AnnounceParams tournament settings as they come from the API, amounts in points.
+type AnnounceParams struct {
+	ID          int     `json:"tournamentId,omitempty"`
+	Deposit     float64 `json:"deposit"`
+	RakePercent float64 `json:"rakePercent,omitempty"`
+	RakeFixed   float64 `json:"rakeFixed,omitempty"`
+	Guarantee   float64 `json:"guarantee,omitempty"`
+	MaxPlayers  int     `json:"maxPlayers,omitempty"`
+	MinPlayers  int     `json:"minPlayers,omitempty"`
+
+	RegistrationOpensAt  *time.Time `json:"registrationOpensAt,omitempty"`
+	RegistrationClosesAt *time.Time `json:"registrationClosesAt,omitempty"`
+	StartsAt             *time.Time `json:"startsAt,omitempty"`
+
+	Format      string    `json:"format,omitempty"`
+	Payouts     []float64 `json:"payouts,omitempty"`
+	SwissRounds int       `json:"swissRounds,omitempty"`
+
+	TeamMode bool   `json:"teamMode,omitempty"`
+	TeamFee  string `json:"teamFee,omitempty"`
+
+	ScoreMode string `json:"scoreMode,omitempty"`
+
+	MaxReEntries  int        `json:"maxReEntries,omitempty"`
+	AddOnPrice    float64    `json:"addOnPrice,omitempty"`
+	MaxAddOns     int        `json:"maxAddOns,omitempty"`
+	AddOnClosesAt *time.Time `json:"addOnClosesAt,omitempty"`
+
+	SatelliteFor     int    `json:"satelliteFor,omitempty"`
+	SatelliteTickets int    `json:"satelliteTickets,omitempty"`
+	TicketUnused     string `json:"ticketUnused,omitempty"`
+}
+
+// TournamentResults results posted by a game server: either a ranking of player ids,
+// best first, or scores per player id, higher is better. Raffle tournaments accept only the client seed.
+type TournamentResults struct {
+	Ranking    []int           `json:"ranking"`
+	Scores     map[int]float64 `json:"scores"`
+	ClientSeed string          `json:"clientSeed"`
+}
+
+// MaxClientSeedLength longest client seed of a raffle draw
+const MaxClientSeedLength = 64
+
+// Draw inputs of a provably fair raffle draw
+type Draw struct {
+	ServerSeed     string
+	ServerSeedHash string
+	ClientSeed     string
+	Players        []int
+}
+
+// Standing final place of a player in the tournament. Players with equal score share the place.
+type Standing struct {
+	PlayerID int
+	Place    int
+	Score    float64
+	Prize    int64
+	// Ticket token of the ticket won in a satellite
+	Ticket string
+}
+
+// House ledger entry kinds
+const (
+	LedgerKindRake    = "rake"
+	LedgerKindOverlay = "overlay"
+	LedgerKindRefund  = "rake_refund"
+	// LedgerKindTicket value of an expired satellite ticket
+	LedgerKindTicket = "ticket_expired"
+)
+
+// HouseAccount operator account which collects the rake
+type HouseAccount struct {
 	ID      int
-	Deposit int64
-	Prize   int64
-	Winner  int
-	Status  int
+	Name    string
+	Balance int64
+}
+
+// HouseLedgerEntry single movement on the house account
+type HouseLedgerEntry struct {
+	ID           int
+	HouseID      int
+	TournamentID int
+	PlayerID     int
+	Amount       int64
+	Kind         string
+	CreatedAt    time.Time
 }
 
-// TournamentPlayer - player takes part in tournament
+// TournamentPlayer - player takes part in tournament. Paid and Rake add up all purchases of the player.
 type TournamentPlayer struct {
 	PlayerID     int
 	TournamentID int
+	Paid         int64
+	Rake         int64
+	Entries      int
+	AddOns       int
+	// TicketID ticket used instead of the deposit
+	TicketID int
+}
+
+// Tournament purchase kinds
+const (
+	PurchaseEntry   = "entry"
+	PurchaseReEntry = "re_entry"
+	PurchaseAddOn   = "add_on"
+	// PurchaseTicketEntry entry paid with a ticket won in a satellite
+	PurchaseTicketEntry = "ticket_entry"
+	// PurchaseRefund points given back when an entry is refunded, the amount and the rake are negative
+	PurchaseRefund = "refund"
+)
+
+// Purchase ledger entry of a player paying into a tournament
+type Purchase struct {
+	ID           int
+	TournamentID int
+	PlayerID     int
+	Kind         string
+	Amount       int64
+	Rake         int64
+	CreatedAt    time.Time
 }
 
 // Results JSON set
@@ -78,7 +421,17 @@ type Results struct {
 
 // Result JSON output
 type Result struct {
-	Winner Winner `json:"winner"`
+	Winner    Winner           `json:"winner"`
+	Standings []StandingResult `json:"standings,omitempty"`
+}
+
+// StandingResult final standing JSON output
+type StandingResult struct {
+	PlayerID int     `json:"playerId"`
+	Place    int     `json:"place"`
+	Prize    float64 `json:"prize"`
+	Balance  float64 `json:"balance"`
+	Ticket   string  `json:"ticket,omitempty"`
 }
 
 // BalanceResults JSON output fro player balance
@@ -87,9 +440,508 @@ type BalanceResults struct {
 	Balance  float64 `json:"balance"`
 }
 
-// Winner user JSON output
+// Winner user JSON output, the balance of the winner is shown only to the operator finishing the tournament
 type Winner struct {
 	PlayerID int     `json:"playerId"`
 	Prize    float64 `json:"prize"`
+	Balance  float64 `json:"balance,omitempty"`
+	Overlay  float64 `json:"overlay,omitempty"`
+}
+
+// HouseResults JSON output for house account balance and ledger
+type HouseResults struct {
+	Balance float64       `json:"balance"`
+	Ledger  []LedgerEntry `json:"ledger"`
+}
+
+// LedgerEntry ledger entry JSON output
+type LedgerEntry struct {
+	TournamentID int       `json:"tournamentId,omitempty"`
+	PlayerID     int       `json:"playerId,omitempty"`
+	Amount       float64   `json:"amount"`
+	Kind         string    `json:"kind"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TournamentState JSON output for tournament status changes
+type TournamentState struct {
+	TournamentID int    `json:"tournamentId"`
+	Status       string `json:"status"`
+	Players      int    `json:"players"`
+}
+
+// Join statuses
+const (
+	JoinStatusJoined     = "joined"
+	JoinStatusWaitlisted = "waitlisted"
+)
+
+// WaitlistEntry player waiting for a free place in a full tournament
+type WaitlistEntry struct {
+	ID           int
+	TournamentID int
+	PlayerID     int
+	CreatedAt    time.Time
+}
+
+// JoinResults JSON output for joining a tournament
+type JoinResults struct {
+	PlayerID     int    `json:"playerId"`
+	TeamID       int    `json:"teamId,omitempty"`
+	TournamentID int    `json:"tournamentId"`
+	Status       string `json:"status"`
+	Position     int    `json:"position,omitempty"`
+	Ticket       string `json:"ticket,omitempty"`
+}
+
+// LeaveResults JSON output for leaving a tournament
+type LeaveResults struct {
+	PlayerID         int     `json:"playerId"`
+	TournamentID     int     `json:"tournamentId"`
+	Refund           float64 `json:"refund"`
+	PromotedPlayerID int     `json:"promotedPlayerId,omitempty"`
+}
+
+// TournamentInfo JSON output for tournament details
+type TournamentInfo struct {
+	ID             int       `json:"tournamentId"`
+	Status         string    `json:"status"`
+	Format         string    `json:"format"`
+	Deposit        float64   `json:"deposit"`
+	Prize          float64   `json:"prize"`
+	Guarantee      float64   `json:"guarantee,omitempty"`
+	Payouts        []float64 `json:"payouts"`
+	ServerSeedHash string    `json:"serverSeedHash,omitempty"`
+}
+
+// DrawVerification JSON output for checking a raffle draw
+type DrawVerification struct {
+	TournamentID   int    `json:"tournamentId"`
+	ServerSeed     string `json:"serverSeed"`
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Players        []int  `json:"players"`
+	Winner         int    `json:"winner"`
+	DrawnWinner    int    `json:"drawnWinner"`
+	Verified       bool   `json:"verified"`
+}
+
+// Match brackets
+const (
+	BracketWinners    = "W"
+	BracketLosers     = "L"
+	BracketGrandFinal = "GF"
+	BracketRoundRobin = "RR"
+	BracketSwiss      = "SW"
+)
+
+// Match statuses
+const (
+	// MatchPending match is waiting for its players
+	MatchPending = "pending"
+	// MatchReady both players are known, waiting for the result
+	MatchReady = "ready"
+	// MatchDone match is over
+	MatchDone = "done"
+)
+
+// Match game between two players of a tournament. WinnerTo and LoserTo are numbers of
+// the matches the players move on to, Filled counts slots which have received their player.
+type Match struct {
+	TournamentID int
+	Number       int
+	Bracket      string
+	Round        int
+	Position     int
+	Player1      int
+	Player2      int
+	Score1       float64
+	Score2       float64
+	Winner       int
+	Status       string
+	Filled       int
+	WinnerTo     int
+	WinnerSlot   int
+	LoserTo      int
+	LoserSlot    int
+}
+
+// MatchResult reported match result
+type MatchResult struct {
+	Winner int     `json:"winner"`
+	Score1 float64 `json:"score1"`
+	Score2 float64 `json:"score2"`
+}
+
+// Matches JSON set
+type Matches struct {
+	Matches []MatchInfo `json:"matches"`
+}
+
+// MatchInfo match JSON output
+type MatchInfo struct {
+	Number   int     `json:"match"`
+	Bracket  string  `json:"bracket"`
+	Round    int     `json:"round"`
+	Position int     `json:"position"`
+	Player1  int     `json:"player1"`
+	Player2  int     `json:"player2"`
+	Score1   float64 `json:"score1"`
+	Score2   float64 `json:"score2"`
+	Winner   int     `json:"winner"`
+	Status   string  `json:"status"`
+}
+
+// LeagueStanding round robin or Swiss standing JSON output
+type LeagueStanding struct {
+	PlayerID int     `json:"playerId"`
+	Place    int     `json:"place"`
+	Points   float64 `json:"points"`
+	Tiebreak float64 `json:"tiebreak"`
+	Played   int     `json:"played"`
+	Wins     int     `json:"wins"`
+	Draws    int     `json:"draws"`
+	Losses   int     `json:"losses"`
+}
+
+// LeagueStandings JSON set
+type LeagueStandings struct {
+	Standings []LeagueStanding `json:"standings"`
+}
+
+// TeamInfo team JSON output
+type TeamInfo struct {
+	ID        int              `json:"teamId"`
+	Name      string           `json:"name"`
+	CaptainID int              `json:"captainId"`
+	Members   []TeamMemberInfo `json:"members"`
+	Invites   []TeamMemberInfo `json:"invites,omitempty"`
+}
+
+// TeamMemberInfo team member JSON output
+type TeamMemberInfo struct {
+	PlayerID int     `json:"playerId"`
+	Share    float64 `json:"share"`
+}
+
+// LeaderboardEntry aggregated score of a player in a leaderboard tournament
+type LeaderboardEntry struct {
+	PlayerID    int       `json:"playerId"`
+	Place       int       `json:"place"`
+	Score       float64   `json:"score"`
+	Submissions int       `json:"submissions"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Leaderboard JSON output for leaderboard tournaments
+type Leaderboard struct {
+	TournamentID int                `json:"tournamentId"`
+	Status       string             `json:"status"`
+	ScoreMode    string             `json:"scoreMode"`
+	Entries      []LeaderboardEntry `json:"entries"`
+}
+
+// PurchaseResults JSON output for re-entries and add-ons
+type PurchaseResults struct {
+	PlayerID     int     `json:"playerId"`
+	TournamentID int     `json:"tournamentId"`
+	Kind         string  `json:"kind"`
+	Amount       float64 `json:"amount"`
+	Entries      int     `json:"entries"`
+	AddOns       int     `json:"addOns"`
+	Prize        float64 `json:"prize"`
+}
+
+// PurchaseInfo purchase ledger JSON output
+type PurchaseInfo struct {
+	PlayerID  int       `json:"playerId"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`
+	Rake      float64   `json:"rake"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Purchases JSON set
+type Purchases struct {
+	TournamentID int            `json:"tournamentId"`
+	Purchases    []PurchaseInfo `json:"purchases"`
+}
+
+// Template settings of a recurring tournament. A tournament is announced from the template
+// LeadMinutes before every start time given by the cron rule Recurrence, its registration
+// closes when it starts.
+type Template struct {
+	ID               int
+	Name             string
+	Params           AnnounceParams
+	Recurrence       string
+	LeadMinutes      int
+	Active           bool
+	NextStartAt      *time.Time
+	LastTournamentID int
+}
+
+// TemplateInfo template JSON output
+type TemplateInfo struct {
+	ID               int            `json:"templateId"`
+	Name             string         `json:"name"`
+	Recurrence       string         `json:"recurrence"`
+	LeadMinutes      int            `json:"leadMinutes"`
+	Active           bool           `json:"active"`
+	NextStartAt      *time.Time     `json:"nextStartAt,omitempty"`
+	LastTournamentID int            `json:"lastTournamentId,omitempty"`
+	Settings         AnnounceParams `json:"settings"`
+}
+
+// Templates JSON set
+type Templates struct {
+	Templates []TemplateInfo `json:"templates"`
+}
+
+// TicketInfo ticket JSON output
+type TicketInfo struct {
+	Token              string     `json:"ticket"`
+	TournamentID       int        `json:"tournamentId"`
+	SourceTournamentID int        `json:"sourceTournamentId"`
+	Value              float64    `json:"value"`
+	Unused             string     `json:"unused"`
+	Status             string     `json:"status"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	SettledAt          *time.Time `json:"settledAt,omitempty"`
+}
+
+// Tickets JSON set
+type Tickets struct {
+	PlayerID int          `json:"playerId"`
+	Tickets  []TicketInfo `json:"tickets"`
+}
+
+// API key scopes of the first keys, they stand for the roles admin, game-server and support
+const (
+	ScopeAdmin    = "admin"
+	ScopeService  = "service"
+	ScopeReadOnly = "read-only"
+)
+
+// Roles of API keys and token holders, players may act only on their own account
+const (
+	RoleAdmin      = "admin"
+	RoleOperator   = "operator"
+	RoleSupport    = "support"
+	RoleGameServer = "game-server"
+	RolePlayer     = "player"
+)
+
+// Permissions required by the API routes
+const (
+	// PermFundPlayers changing player balances
+	PermFundPlayers = "players:fund"
+	// PermViewPlayers viewing balances, tickets and purchase history
+	PermViewPlayers = "players:view"
+	// PermSetLimits setting responsible-gaming limits and self-exclusion
+	PermSetLimits = "players:limits"
+	// PermWithdraw withdrawing from a player balance
+	PermWithdraw = "players:withdraw"
+	// PermManagePlayers changing the account status of players and reviewing their verification
+	PermManagePlayers = "players:manage"
+	// PermManageTournaments announcing, starting and finishing tournaments, managing templates
+	PermManageTournaments = "tournaments:manage"
+	// PermPlay joining and leaving tournaments, buying re-entries and add-ons, managing teams
+	PermPlay = "tournaments:play"
+	// PermReportResults posting tournament results, submitting scores and match results
+	PermReportResults = "results:report"
+	// PermViewTournaments viewing tournaments, standings and templates
+	PermViewTournaments = "tournaments:view"
+	// PermViewHouse viewing the house account
+	PermViewHouse = "house:view"
+	// PermViewAudit reading, verifying and exporting the audit log
+	PermViewAudit = "audit:view"
+	// PermViewMetrics reading the service metrics
+	PermViewMetrics = "metrics:view"
+)
+
+// APIKey key of an API client, only the SHA-256 hash of the key is stored
+type APIKey struct {
+	ID        int
+	Name      string
+	Hash      string
+	Scope     string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Audit actions
+const (
+	AuditFundPlayer         = "fund_player"
+	AuditAnnounceTournament = "announce_tournament"
+	AuditStartTournament    = "start_tournament"
+	AuditFinishTournament   = "finish_tournament"
+	AuditCreateTemplate     = "create_template"
+	AuditUpdateTemplate     = "update_template"
+	AuditActivateTemplate   = "activate_template"
+	AuditDeleteTemplate     = "delete_template"
+	AuditSetAccountStatus   = "set_account_status"
+	AuditReviewKYC          = "review_kyc"
+	AuditWithdraw           = "withdraw"
+	AuditCreateAPIKey       = "create_api_key"
+	AuditRotateAPIKey       = "rotate_api_key"
+	AuditRevokeAPIKey       = "revoke_api_key"
+)
+
+// AuditRecord administrative action. Every record keeps the hash of the previous one,
+// so changing or removing a record breaks the chain.
+type AuditRecord struct {
+	ID        int             `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	SourceIP  string          `json:"sourceIp"`
+	RequestID string          `json:"requestId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditFilter selects audit records, empty fields match every record
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+}
+
+// AuditRecords JSON set
+type AuditRecords struct {
+	Records []AuditRecord `json:"records"`
+}
+
+// AuditVerification result of checking the audit log hash chain
+type AuditVerification struct {
+	Records  int  `json:"records"`
+	Valid    bool `json:"valid"`
+	BrokenAt int  `json:"brokenAt,omitempty"`
+}
+
+// Responsible-gaming limit kinds: funding of the player account and tournament spend
+const (
+	LimitDeposit = "deposit"
+	LimitSpend   = "spend"
+)
+
+// Responsible-gaming limit periods, the periods are rolling windows of 24 hours, 7 days and 30 days
+const (
+	LimitDaily   = "daily"
+	LimitWeekly  = "weekly"
+	LimitMonthly = "monthly"
+)
+
+// PlayerLimit responsible-gaming limit of a player. A raised or removed limit waits in PendingAmount
+// until PendingFrom, zero PendingAmount removes the limit.
+type PlayerLimit struct {
+	PlayerID      int
+	Kind          string
+	Period        string
+	Amount        int64
+	PendingAmount int64
+	PendingFrom   *time.Time
+}
+
+// LimitInfo JSON output of a limit with the amount used in its current period
+type LimitInfo struct {
+	Kind          string     `json:"kind"`
+	Period        string     `json:"period"`
+	Amount        float64    `json:"amount"`
+	Used          float64    `json:"used"`
+	PendingAmount *float64   `json:"pendingAmount,omitempty"`
+	PendingFrom   *time.Time `json:"pendingFrom,omitempty"`
+}
+
+// PlayerLimits JSON set
+type PlayerLimits struct {
+	PlayerID      int         `json:"playerId"`
+	ExcludedUntil *time.Time  `json:"excludedUntil,omitempty"`
+	Limits        []LimitInfo `json:"limits"`
+}
+
+// Player account statuses, money operations are allowed only on active accounts
+const (
+	AccountActive       = "active"
+	AccountSuspended    = "suspended"
+	AccountSelfExcluded = "self_excluded"
+	AccountClosed       = "closed"
+)
+
+// Policies for the open tournament entries of suspended players
+const (
+	// EntryPolicyKeep the player stays in the tournaments
+	EntryPolicyKeep = "keep"
+	// EntryPolicyRefund the player is removed from tournaments which have not started and the entries are refunded
+	EntryPolicyRefund = "refund"
+)
+
+// AccountStatusChange change of a player account status
+type AccountStatusChange struct {
+	PlayerID      int        `json:"-"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason"`
+	ExcludedUntil *time.Time `json:"excludedUntil,omitempty"`
+	Actor         string     `json:"actor"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// AccountInfo JSON output of a player account status with its history, newest change first
+type AccountInfo struct {
+	PlayerID        int                   `json:"playerId"`
+	Status          string                `json:"status"`
+	Reason          string                `json:"reason,omitempty"`
+	ChangedAt       *time.Time            `json:"changedAt,omitempty"`
+	ExcludedUntil   *time.Time            `json:"excludedUntil,omitempty"`
+	RefundedEntries []int                 `json:"refundedEntries,omitempty"`
+	History         []AccountStatusChange `json:"history"`
+}
+
+// KYC verification statuses of players
+const (
+	KYCUnverified = "unverified"
+	KYCPending    = "pending"
+	KYCVerified   = "verified"
+	KYCRejected   = "rejected"
+)
+
+// KYC document kinds
+const (
+	DocumentPassport       = "passport"
+	DocumentIDCard         = "id_card"
+	DocumentDrivingLicence = "driving_licence"
+	DocumentProofOfAddress = "proof_of_address"
+)
+
+// KYCDocument reference to a document the player submitted for verification, the document itself is kept elsewhere
+type KYCDocument struct {
+	ID        int       `json:"id"`
+	PlayerID  int       `json:"-"`
+	Kind      string    `json:"kind"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// KYCInfo JSON output of the player verification
+type KYCInfo struct {
+	PlayerID   int           `json:"playerId"`
+	Status     string        `json:"status"`
+	Note       string        `json:"note,omitempty"`
+	ReviewedAt *time.Time    `json:"reviewedAt,omitempty"`
+	Reviewer   string        `json:"reviewer,omitempty"`
+	Documents  []KYCDocument `json:"documents"`
+}
+
+// WithdrawResults JSON output of a withdrawal
+type WithdrawResults struct {
+	PlayerID int     `json:"playerId"`
+	Amount   float64 `json:"amount"`
 	Balance  float64 `json:"balance"`
 }
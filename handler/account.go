@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func setAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SetAccountStatus(db, playerID, vars["status"], vars["reason"], actor(r))
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func accountHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetAccount(db, playerID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+)
+
+// requestIDHeader request header carrying the request id, it is generated when the client sends none
+const requestIDHeader = "X-Request-ID"
+
+// statusRecorder keeps the status and the body written by the handler until flush sends them.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.body.Write(b)
+}
+
+// flush sends the kept response to the client.
+func (s *statusRecorder) flush() {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	s.ResponseWriter.WriteHeader(s.status)
+	s.ResponseWriter.Write(s.body.Bytes())
+}
+
+// requestID returns the request id of the request and sends it back to the client.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > 64 {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+// sourceIP returns the address of the client.
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// actor returns the name of the authenticated caller for the audit log.
+func actor(r *http.Request) string {
+	p, ok := r.Context().Value(principalContextKey).(principal)
+	if !ok {
+		return "anonymous"
+	}
+	if p.KeyID != 0 {
+		return "api_key:" + strconv.Itoa(p.KeyID) + " " + p.Subject
+	}
+	return p.Role + ":" + p.Subject
+}
+
+// audited writes an audit record with the state of the target before and after a successful request.
+// The target id is read from the param, or from the response when the request creates the target.
+// The audit log stays locked from the first snapshot until the record is written, and the response
+// is held back until then: when the action can't be audited the client gets an error, not the result.
+func audited(action string, kind string, param string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record := entity.AuditRecord{
+			Actor:     actor(r),
+			Action:    action,
+			SourceIP:  sourceIP(r),
+			RequestID: requestID(w, r),
+		}
+		audit, err := controller.BeginAudit(db)
+		if err != nil {
+			http.Error(w, "this is Database Error", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		defer audit.Close()
+		id, err := strconv.Atoi(mux.Vars(r)[param])
+		if err == nil {
+			record.Target = controller.AuditTarget(kind, id)
+			record.Before, err = controller.AuditSnapshot(db, record.Target)
+			if err != nil {
+				http.Error(w, "this is Database Error", http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if rec.status >= http.StatusBadRequest {
+			rec.flush()
+			return
+		}
+		if record.Target == "" {
+			created := map[string]json.RawMessage{}
+			if json.Unmarshal(rec.body.Bytes(), &created) == nil {
+				id, err = strconv.Atoi(string(created[param]))
+			}
+			if err != nil {
+				http.Error(w, "this is Database Error", http.StatusInternalServerError)
+				log.Println("audit: no target id in the response of", action)
+				return
+			}
+			record.Target = controller.AuditTarget(kind, id)
+		}
+		record.After, err = controller.AuditSnapshot(db, record.Target)
+		if err == nil {
+			err = audit.Write(record)
+		}
+		if err != nil {
+			http.Error(w, "this is Database Error", http.StatusInternalServerError)
+			log.Printf("audit: %s of %s by %s was not recorded: %s", action, record.Target, record.Actor, err)
+			return
+		}
+		rec.flush()
+	}
+}
+
+// auditFilter parse the optional audit filter parameters, it writes the error response
+// and returns false when a parameter is invalid.
+func auditFilter(w http.ResponseWriter, r *http.Request) (entity.AuditFilter, bool) {
+	query := r.URL.Query()
+	filter := entity.AuditFilter{Actor: query.Get("actor"), Action: query.Get("action"), Target: query.Get("target")}
+	var err error
+	filter.From, err = optionalTime(r, "from")
+	if err != nil {
+		http.Error(w, "there was a missing or invalid from parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return filter, false
+	}
+	filter.To, err = optionalTime(r, "to")
+	if err != nil {
+		http.Error(w, "there was a missing or invalid to parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return filter, false
+	}
+	filter.Limit, err = optionalInt(r, "limit")
+	if err != nil || filter.Limit < 0 {
+		http.Error(w, "there was a missing or invalid limit parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return filter, false
+	}
+	return filter, true
+}
+
+func auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+	js, err := controller.GetAuditLog(db, filter)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func verifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := controller.VerifyAuditLog(db)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func exportAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	contentType := "application/x-ndjson"
+	switch format {
+	case "", controller.AuditFormatJSON:
+		format = controller.AuditFormatJSON
+	case controller.AuditFormatCSV:
+		contentType = "text/csv"
+	default:
+		http.Error(w, "there was a missing or invalid format parameter..", http.StatusBadRequest)
+		return
+	}
+	data, err := controller.ExportAuditLog(db, filter, format)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=audit."+map[string]string{
+		controller.AuditFormatJSON: "jsonl",
+		controller.AuditFormatCSV:  "csv",
+	}[format])
+	w.Write(data)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+)
+
+// apiKeyHeader request header carrying the API key
+const apiKeyHeader = "X-API-Key"
+
+// routePermissions permission declared by every route, routes without a permission are not served.
+var routePermissions = map[string]string{}
+
+// playerRoutes routes players may call for their own account, mapped to the parameter holding the player id.
+// Other routes need the permission without an account check, players get only tournament views there.
+var playerRoutes = map[string]string{
+	"/joinTournament":     "playerId",
+	"/leaveTournament":    "playerId",
+	"/reEnterTournament":  "playerId",
+	"/buyAddOn":           "playerId",
+	"/balance":            "playerId",
+	"/tickets":            "playerId",
+	"/createTeam":         "captainId",
+	"/addTeamMember":      "captainId",
+	"/acceptTeamInvite":   "playerId",
+	"/joinTeamTournament": "captainId",
+	"/setLimit":           "playerId",
+	"/selfExclude":        "playerId",
+	"/limits":             "playerId",
+	"/account":            "playerId",
+	"/submitKYCDocument":  "playerId",
+	"/kyc":                "playerId",
+	"/withdraw":           "playerId",
+}
+
+// handle registers the route together with the permission it requires.
+func handle(router *mux.Router, path string, permission string, f func(http.ResponseWriter, *http.Request)) *mux.Route {
+	routePermissions[path] = permission
+	return router.HandleFunc(path, f)
+}
+
+type contextKey string
+
+// request context key of the authenticated caller
+const principalContextKey = contextKey("principal")
+
+// principal authenticated caller: an API key or the subject of a token, with its role
+type principal struct {
+	Role    string
+	Subject string
+	KeyID   int
+}
+
+// routePath returns the path template of the matched route.
+func routePath(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// allowed reports whether the caller may call the route. Players may use their permissions
+// only for their own account, apart from viewing tournaments.
+func allowed(r *http.Request, p principal) bool {
+	permission, ok := routePermissions[routePath(r)]
+	if !ok || !controller.RoleAllows(p.Role, permission) {
+		return false
+	}
+	if p.Role != entity.RolePlayer || permission == entity.PermViewTournaments {
+		return true
+	}
+	param, ok := playerRoutes[routePath(r)]
+	return ok && mux.Vars(r)[param] == p.Subject
+}
+
+// bearerToken returns the token of the Authorization header or an empty string.
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// clientCommonName returns the common name of the verified client certificate or an empty string.
+func clientCommonName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// authenticate returns the caller of the request from its API key, token or client certificate.
+func authenticate(r *http.Request) (principal, int, string) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		apiKey, err := controller.AuthenticateAPIKey(db, key)
+		if err != nil {
+			log.Println(err)
+			return principal{}, http.StatusUnauthorized, "invalid API key"
+		}
+		return principal{Role: controller.KeyRole(apiKey.Scope), Subject: apiKey.Name, KeyID: apiKey.ID}, 0, ""
+	}
+	if token := bearerToken(r); token != "" && verifier != nil {
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			log.Println(err)
+			return principal{}, http.StatusUnauthorized, "invalid token"
+		}
+		role := controller.TokenRole(claims.Role)
+		if role == "" {
+			return principal{}, http.StatusForbidden, "unknown role"
+		}
+		return principal{Role: role, Subject: claims.Subject}, 0, ""
+	}
+	if cn := clientCommonName(r); cn != "" {
+		if role, ok := clientRoles[cn]; ok {
+			return principal{Role: role, Subject: "cert:" + cn}, 0, ""
+		}
+		return principal{}, http.StatusForbidden, "unknown client certificate"
+	}
+	return principal{}, http.StatusUnauthorized, "missing API key or token"
+}
+
+// authMiddleware rejects requests without valid credentials or whose role lacks the permission of the route.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, status, message := authenticate(r)
+		if status != 0 {
+			http.Error(w, message, status)
+			return
+		}
+		if !allowed(r, p) {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey, p)))
+	})
+}
@@ -2,28 +2,100 @@ package handler
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/mishelini/auth"
 	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
 )
 
 var db *sql.DB
 
+// Options optional parts of the API, nil and zero values disable them
+type Options struct {
+	// TokenVerifier checks bearer tokens
+	TokenVerifier *auth.Verifier
+	// Signatures checks signed requests of game-server integrations
+	Signatures *auth.SignatureVerifier
+	// RateLimits request rates per client IP, API key and player
+	RateLimits entity.RateLimits
+	// ClientRoles roles of the services by the common name of their verified client certificate
+	ClientRoles map[string]string
+}
+
+// verifier checks player tokens, nil when tokens are not accepted
+var verifier *auth.Verifier
+
+// signatures checks signed requests of game-server integrations, nil when signing is disabled
+var signatures *auth.SignatureVerifier
+
+// clientRoles roles of the services authenticated by client certificates
+var clientRoles map[string]string
+
 // Handler returns router mux
-func Handler(db2 *sql.DB) *mux.Router {
+func Handler(db2 *sql.DB, options Options) *mux.Router {
 	db = db2
+	verifier = options.TokenVerifier
+	signatures = options.Signatures
+	clientRoles = options.ClientRoles
+	limiters = newLimiters(options.RateLimits)
 	route := mux.NewRouter()
-	route.HandleFunc("/fund", fundPlayerHandler).Queries("playerId", "{playerId:[0-9]+}", "points", "{points:[0-9]+}").Methods("GET")
-	route.HandleFunc("/announceTournament", announceTournamentHandler).Queries("tournamentId", "{tournamentId:[0-9]+}", "deposit", "{deposit:[0-9]+}").Methods("GET")
-	route.HandleFunc("/joinTournament", joinTournamentHandler).Queries("playerId", "{playerId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
-	route.HandleFunc("/finishTournament", finishTournamentHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
-	route.HandleFunc("/resultTournament", resultTournamentHandler).Methods("GET")
-	route.HandleFunc("/balance", playerBalanceHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/fund", entity.PermFundPlayers, audited(entity.AuditFundPlayer, "player", "playerId", fundPlayerHandler)).Queries("playerId", "{playerId:[0-9]+}", "points", "{points:[0-9]+}").Methods("GET")
+	handle(route, "/announceTournament", entity.PermManageTournaments, audited(entity.AuditAnnounceTournament, "tournament", "tournamentId", announceTournamentHandler)).Queries("tournamentId", "{tournamentId:[0-9]+}", "deposit", "{deposit:[0-9]+}").Methods("GET")
+	handle(route, "/joinTournament", entity.PermPlay, joinTournamentHandler).Queries("playerId", "{playerId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/leaveTournament", entity.PermPlay, leaveTournamentHandler).Queries("playerId", "{playerId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/startTournament", entity.PermManageTournaments, audited(entity.AuditStartTournament, "tournament", "tournamentId", startTournamentHandler)).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/finishTournament", entity.PermReportResults, audited(entity.AuditFinishTournament, "tournament", "tournamentId", finishTournamentHandler)).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET", "POST")
+	handle(route, "/resultTournament", entity.PermViewTournaments, resultTournamentHandler).Methods("GET")
+	handle(route, "/balance", entity.PermViewPlayers, playerBalanceHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/tournament", entity.PermViewTournaments, tournamentHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/verifyDraw", entity.PermViewTournaments, verifyDrawHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/generateBracket", entity.PermManageTournaments, generateBracketHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/generateRound", entity.PermManageTournaments, generateRoundHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/standings", entity.PermViewTournaments, standingsHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/reportMatch", entity.PermReportResults, reportMatchHandler).Queries("tournamentId", "{tournamentId:[0-9]+}", "match", "{match:[0-9]+}").Methods("POST")
+	handle(route, "/matches", entity.PermViewTournaments, matchesHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/house", entity.PermViewHouse, houseAccountHandler).Methods("GET")
+	handle(route, "/createTeam", entity.PermPlay, createTeamHandler).Queries("name", "{name}", "captainId", "{captainId:[0-9]+}").Methods("GET")
+	handle(route, "/addTeamMember", entity.PermPlay, addTeamMemberHandler).Queries("teamId", "{teamId:[0-9]+}", "captainId", "{captainId:[0-9]+}", "playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/acceptTeamInvite", entity.PermPlay, acceptTeamInviteHandler).Queries("teamId", "{teamId:[0-9]+}", "playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/team", entity.PermViewTournaments, teamHandler).Queries("teamId", "{teamId:[0-9]+}").Methods("GET")
+	handle(route, "/joinTeamTournament", entity.PermPlay, joinTeamTournamentHandler).Queries("teamId", "{teamId:[0-9]+}", "captainId", "{captainId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/submitScore", entity.PermReportResults, submitScoreHandler).Queries("tournamentId", "{tournamentId:[0-9]+}", "playerId", "{playerId:[0-9]+}", "score", "{score:-?[0-9.]+}").Methods("GET", "POST")
+	handle(route, "/leaderboard", entity.PermViewTournaments, leaderboardHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/reEnterTournament", entity.PermPlay, reEnterTournamentHandler).Queries("playerId", "{playerId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/buyAddOn", entity.PermPlay, buyAddOnHandler).Queries("playerId", "{playerId:[0-9]+}", "tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/purchases", entity.PermViewPlayers, purchasesHandler).Queries("tournamentId", "{tournamentId:[0-9]+}").Methods("GET")
+	handle(route, "/createTemplate", entity.PermManageTournaments, audited(entity.AuditCreateTemplate, "template", "templateId", createTemplateHandler)).Queries("name", "{name}", "deposit", "{deposit:[0-9]+}", "recurrence", "{recurrence}", "leadMinutes", "{leadMinutes:[0-9]+}").Methods("GET")
+	handle(route, "/updateTemplate", entity.PermManageTournaments, audited(entity.AuditUpdateTemplate, "template", "templateId", updateTemplateHandler)).Queries("templateId", "{templateId:[0-9]+}", "name", "{name}", "deposit", "{deposit:[0-9]+}", "recurrence", "{recurrence}", "leadMinutes", "{leadMinutes:[0-9]+}").Methods("GET")
+	handle(route, "/activateTemplate", entity.PermManageTournaments, audited(entity.AuditActivateTemplate, "template", "templateId", activateTemplateHandler)).Queries("templateId", "{templateId:[0-9]+}", "active", "{active:true|false}").Methods("GET")
+	handle(route, "/deleteTemplate", entity.PermManageTournaments, audited(entity.AuditDeleteTemplate, "template", "templateId", deleteTemplateHandler)).Queries("templateId", "{templateId:[0-9]+}").Methods("GET")
+	handle(route, "/template", entity.PermViewTournaments, templateHandler).Queries("templateId", "{templateId:[0-9]+}").Methods("GET")
+	handle(route, "/templates", entity.PermViewTournaments, templatesHandler).Methods("GET")
+	handle(route, "/tickets", entity.PermViewPlayers, ticketsHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/audit", entity.PermViewAudit, auditLogHandler).Methods("GET")
+	handle(route, "/verifyAudit", entity.PermViewAudit, verifyAuditLogHandler).Methods("GET")
+	handle(route, "/exportAudit", entity.PermViewAudit, exportAuditLogHandler).Methods("GET")
+	handle(route, "/setLimit", entity.PermSetLimits, setLimitHandler).Queries("playerId", "{playerId:[0-9]+}", "kind", "{kind}", "period", "{period}", "amount", "{amount:[0-9.]+}").Methods("GET")
+	handle(route, "/selfExclude", entity.PermSetLimits, selfExcludeHandler).Queries("playerId", "{playerId:[0-9]+}", "days", "{days:[0-9]+}").Methods("GET")
+	handle(route, "/limits", entity.PermViewPlayers, limitsHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/setAccountStatus", entity.PermManagePlayers, audited(entity.AuditSetAccountStatus, "account", "playerId", setAccountStatusHandler)).Queries("playerId", "{playerId:[0-9]+}", "status", "{status}", "reason", "{reason}").Methods("GET")
+	handle(route, "/account", entity.PermViewPlayers, accountHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/submitKYCDocument", entity.PermPlay, submitKYCDocumentHandler).Queries("playerId", "{playerId:[0-9]+}", "kind", "{kind}", "reference", "{reference}").Methods("GET")
+	handle(route, "/reviewKYC", entity.PermManagePlayers, audited(entity.AuditReviewKYC, "kyc", "playerId", reviewKYCHandler)).Queries("playerId", "{playerId:[0-9]+}", "decision", "{decision}").Methods("GET")
+	handle(route, "/kyc", entity.PermViewPlayers, kycHandler).Queries("playerId", "{playerId:[0-9]+}").Methods("GET")
+	handle(route, "/withdraw", entity.PermWithdraw, audited(entity.AuditWithdraw, "player", "playerId", withdrawHandler)).Queries("playerId", "{playerId:[0-9]+}", "points", "{points:[0-9.]+}").Methods("GET")
+	handle(route, "/metrics", entity.PermViewMetrics, metricsHandler).Methods("GET")
+	route.Use(rateLimitMiddleware, authMiddleware, callerRateLimitMiddleware, signatureMiddleware)
 	return route
 }
 
@@ -64,12 +136,53 @@ func announceTournamentHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	err = controller.AnnounceTournament(db, id, deposit)
+	params := entity.AnnounceParams{ID: id, Deposit: deposit}
+	if !announceParams(w, r, &params) {
+		return
+	}
+	err = controller.AnnounceTournament(db, params)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	tournamentHandler(w, r)
+}
+
+func tournamentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetTournament(db, tournamentID)
 	if err != nil {
 		http.Error(w, "this is Database Error", http.StatusInternalServerError)
 		log.Println(err)
 		return
 	}
+	w.Write(js)
+}
+
+func verifyDrawHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.VerifyDraw(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
 }
 
 func joinTournamentHandler(w http.ResponseWriter, r *http.Request) {
@@ -87,12 +200,42 @@ func joinTournamentHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	err = controller.JoinTournament(db, userID, tournamentID)
+	var js []byte
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		js, err = controller.JoinTournamentWithTicket(db, userID, tournamentID, ticket)
+	} else {
+		js, err = controller.JoinTournament(db, userID, tournamentID)
+	}
 	if err != nil {
 		http.Error(w, "this is Database Error", http.StatusInternalServerError)
 		log.Println(err)
 		return
 	}
+	w.Write(js)
+}
+
+func leaveTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.LeaveTournament(db, userID, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
 }
 
 func resultTournamentHandler(w http.ResponseWriter, r *http.Request) {
@@ -106,6 +249,24 @@ func resultTournamentHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func startTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.StartTournament(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
 func finishTournamentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
@@ -115,7 +276,32 @@ func finishTournamentHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	js, err := controller.FinishTournament(db, tournamentID)
+	var results *entity.TournamentResults
+	clientSeed := r.URL.Query().Get("clientSeed")
+	if clientSeed != "" {
+		results = &entity.TournamentResults{ClientSeed: clientSeed}
+	}
+	if r.Method == http.MethodPost {
+		results = &entity.TournamentResults{}
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, auth.MaxBodySize)).Decode(results)
+		if err != nil {
+			http.Error(w, "there was an invalid tournament results body..", http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		if clientSeed != "" {
+			if results.ClientSeed != "" {
+				http.Error(w, "there was a clientSeed both in the query and in the body..", http.StatusBadRequest)
+				return
+			}
+			results.ClientSeed = clientSeed
+		}
+	}
+	if results != nil && len(results.ClientSeed) > entity.MaxClientSeedLength {
+		http.Error(w, "there was a too long clientSeed parameter..", http.StatusBadRequest)
+		return
+	}
+	js, err := controller.FinishTournament(db, tournamentID, results)
 	if err != nil {
 		http.Error(w, "this is Database Error", http.StatusInternalServerError)
 		log.Println(err)
@@ -141,3 +327,323 @@ func playerBalanceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Fprintf(w, string(js))
 }
+
+func houseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := controller.GetHouseAccount(db)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+// announceParams parse the optional tournament settings, it writes the error response and returns false
+// when a parameter is invalid.
+func announceParams(w http.ResponseWriter, r *http.Request, p *entity.AnnounceParams) bool {
+	var err error
+	p.RakePercent, err = optionalFloat(r, "rakePercent")
+	if err != nil {
+		http.Error(w, "there was an invalid rakePercent parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.RakeFixed, err = optionalFloat(r, "rakeFixed")
+	if err != nil {
+		http.Error(w, "there was an invalid rakeFixed parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.Guarantee, err = optionalFloat(r, "guarantee")
+	if err != nil {
+		http.Error(w, "there was an invalid guarantee parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.MaxPlayers, err = optionalInt(r, "maxPlayers")
+	if err != nil {
+		http.Error(w, "there was an invalid maxPlayers parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.MinPlayers, err = optionalInt(r, "minPlayers")
+	if err != nil {
+		http.Error(w, "there was an invalid minPlayers parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.RegistrationOpensAt, err = optionalTime(r, "registrationOpensAt")
+	if err != nil {
+		http.Error(w, "there was an invalid registrationOpensAt parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.RegistrationClosesAt, err = optionalTime(r, "registrationClosesAt")
+	if err != nil {
+		http.Error(w, "there was an invalid registrationClosesAt parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.StartsAt, err = optionalTime(r, "startsAt")
+	if err != nil {
+		http.Error(w, "there was an invalid startsAt parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.Format = r.URL.Query().Get("format")
+	p.Payouts, err = optionalFloatList(r, "payouts")
+	if err != nil {
+		http.Error(w, "there was an invalid payouts parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.SwissRounds, err = optionalInt(r, "swissRounds")
+	if err != nil {
+		http.Error(w, "there was an invalid swissRounds parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.TeamMode, err = optionalBool(r, "teamMode")
+	if err != nil {
+		http.Error(w, "there was an invalid teamMode parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.TeamFee = r.URL.Query().Get("teamFee")
+	p.ScoreMode = r.URL.Query().Get("scoreMode")
+	p.MaxReEntries, err = optionalInt(r, "maxReEntries")
+	if err != nil {
+		http.Error(w, "there was an invalid maxReEntries parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.AddOnPrice, err = optionalFloat(r, "addOnPrice")
+	if err != nil {
+		http.Error(w, "there was an invalid addOnPrice parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.MaxAddOns, err = optionalInt(r, "maxAddOns")
+	if err != nil {
+		http.Error(w, "there was an invalid maxAddOns parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.AddOnClosesAt, err = optionalTime(r, "addOnClosesAt")
+	if err != nil {
+		http.Error(w, "there was an invalid addOnClosesAt parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.SatelliteFor, err = optionalInt(r, "satelliteFor")
+	if err != nil {
+		http.Error(w, "there was an invalid satelliteFor parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.SatelliteTickets, err = optionalInt(r, "satelliteTickets")
+	if err != nil {
+		http.Error(w, "there was an invalid satelliteTickets parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return false
+	}
+	p.TicketUnused = r.URL.Query().Get("ticketUnused")
+	return true
+}
+
+// optionalFloat parse optional finite float query parameter, missing parameter is 0.
+func optionalFloat(r *http.Request, name string) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	return parseFinite(value)
+}
+
+// parseFinite parse float, NaN and infinities are refused.
+func parseFinite(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", value)
+	}
+	return f, nil
+}
+
+// optionalInt parse optional integer query parameter, missing parameter is 0.
+func optionalInt(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// optionalBool parse optional boolean query parameter, missing parameter is false.
+func optionalBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// optionalTime parse optional RFC 3339 time query parameter, missing parameter is nil.
+func optionalTime(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// optionalFloatList parse optional comma separated list of floats, missing parameter is nil.
+func optionalFloatList(r *http.Request, name string) ([]float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, ",")
+	list := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		f, err := parseFinite(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+func generateBracketHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	seeds, err := optionalIntList(r, "seeds")
+	if err != nil {
+		http.Error(w, "there was an invalid seeds parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GenerateBracket(db, tournamentID, seeds)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func generateRoundHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GenerateRound(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func standingsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetStandings(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func reportMatchHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	number, err := strconv.Atoi(vars["match"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid match  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	var result entity.MatchResult
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, auth.MaxBodySize)).Decode(&result)
+	if err != nil {
+		http.Error(w, "there was an invalid match result body..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.ReportMatchResult(db, tournamentID, number, result)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func matchesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetMatches(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+// optionalIntList parse optional comma separated list of integers, missing parameter is nil.
+func optionalIntList(r *http.Request, name string) ([]int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, ",")
+	list := make([]int, 0, len(parts))
+	for _, part := range parts {
+		i, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, i)
+	}
+	return list, nil
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/auth"
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// testRouter returns the API accepting HS256 tokens signed with testSecret. There is no database,
+// the tests only send requests which are answered before a handler needs it.
+func testRouter() *mux.Router {
+	return Handler(nil, Options{TokenVerifier: auth.NewVerifier(testSecret, nil, "", "")})
+}
+
+// testToken returns a token of the subject with the role, an empty role is a player.
+func testToken(t *testing.T, subject string, role string) string {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	assert.NoError(t, err, "marshal header failed")
+	payload, err := json.Marshal(map[string]interface{}{"sub": subject, "role": role, "exp": time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err, "marshal claims failed")
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, testSecret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// serve sends the request with the token through the router and returns the status code.
+func serve(router *mux.Router, target string, token string) int {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestOptionalFloat(t *testing.T) {
+	for _, value := range []string{"NaN", "nan", "Inf", "-Inf", "+Inf", "1e400"} {
+		r := httptest.NewRequest("GET", "/announceTournament?rakePercent="+value, nil)
+		_, err := optionalFloat(r, "rakePercent")
+		assert.Error(t, err, "%s should be refused", value)
+	}
+	r := httptest.NewRequest("GET", "/announceTournament?rakePercent=2.5", nil)
+	f, err := optionalFloat(r, "rakePercent")
+	assert.NoError(t, err, "finite value should be accepted")
+	assert.Equal(t, 2.5, f, "value mismatch")
+	f, err = optionalFloat(httptest.NewRequest("GET", "/announceTournament", nil), "rakePercent")
+	assert.NoError(t, err, "missing value should be accepted")
+	assert.Equal(t, 0.0, f, "missing value should be 0")
+}
+
+func TestPlayerTokenSubject(t *testing.T) {
+	router := testRouter()
+	token := testToken(t, "7", "")
+
+	for _, target := range []string{
+		"/balance?playerId=8",
+		"/joinTournament?playerId=8&tournamentId=1",
+		"/withdraw?playerId=8&points=1",
+		"/setLimit?playerId=8&kind=deposit&period=day&amount=1",
+	} {
+		assert.Equal(t, http.StatusForbidden, serve(router, target, token), "%s should be refused for another player", target)
+	}
+	assert.Equal(t, http.StatusBadRequest, serve(router, "/setLimit?playerId=7&kind=deposit&period=day&amount=1.2.3", token),
+		"player should pass the middleware for his own account")
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "/balance?playerId=7", token[:len(token)-2]+"xx"),
+		"token with a broken signature should be rejected")
+}
+
+func TestRoleRoutes(t *testing.T) {
+	router := testRouter()
+
+	for _, c := range []struct {
+		role   string
+		target string
+	}{
+		{entity.RoleSupport, "/fund?playerId=7&points=10"},
+		{entity.RoleOperator, "/fund?playerId=7&points=10"},
+		{entity.RoleOperator, "/withdraw?playerId=7&points=10"},
+		{entity.RoleGameServer, "/withdraw?playerId=7&points=10"},
+		{entity.RoleGameServer, "/setLimit?playerId=7&kind=deposit&period=day&amount=1"},
+		{entity.RoleGameServer, "/selfExclude?playerId=7&days=30"},
+		{entity.RoleGameServer, "/fund?playerId=7&points=10"},
+		{"", "/fund?playerId=7&points=10"},
+		{"", "/withdraw?playerId=8&points=10"},
+		{"", "/account?playerId=8"},
+		{"", "/kyc?playerId=8"},
+		{"", "/joinTeamTournament?teamId=1&captainId=8&tournamentId=1"},
+	} {
+		assert.Equal(t, http.StatusForbidden, serve(router, c.target, testToken(t, "7", c.role)),
+			"%q should not use %s", c.role, c.target)
+	}
+	assert.Equal(t, http.StatusForbidden, serve(router, "/balance?playerId=7", testToken(t, "7", "superuser")),
+		"unknown role should be refused")
+}
+
+func TestGameServerResults(t *testing.T) {
+	router := Handler(nil, Options{TokenVerifier: auth.NewVerifier(testSecret, nil, "", ""),
+		Signatures: auth.NewSignatureVerifier(map[string][]byte{"game-1": testSecret}, time.Minute)})
+	defer func() { signatures = nil }()
+
+	r := httptest.NewRequest("POST", "/finishTournament?tournamentId=7", bytes.NewReader([]byte(`{"ranking":[1,2]}`)))
+	r.Header.Set("Authorization", "Bearer "+testToken(t, "game-1", entity.RoleGameServer))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "game server should post results signed")
+	assert.Equal(t, http.StatusForbidden, serve(router, "/finishTournament?tournamentId=7", testToken(t, "7", "")),
+		"player should not post results")
+}
+
+func TestSignatureReplay(t *testing.T) {
+	used := map[string]bool{}
+	useRequestNonce = func(db *sql.DB, integration string, nonce string, expiresAt time.Time) (bool, error) {
+		if used[integration+" "+nonce] {
+			return false, nil
+		}
+		used[integration+" "+nonce] = true
+		return true, nil
+	}
+	defer func() { useRequestNonce = controller.UseRequestNonce }()
+	signatures = auth.NewSignatureVerifier(map[string][]byte{"game-1": testSecret}, time.Minute)
+	defer func() { signatures = nil }()
+	router := mux.NewRouter()
+	router.HandleFunc("/finishTournament", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	router.HandleFunc("/reportMatch", reportMatchHandler).Queries("tournamentId", "{tournamentId:[0-9]+}", "match", "{match:[0-9]+}").Methods("POST")
+	router.Use(signatureMiddleware)
+
+	body := []byte(`{"ranking":[1,2]}`)
+	post := func(nonce string, body []byte) int {
+		r := httptest.NewRequest("POST", "/finishTournament?tournamentId=7", bytes.NewReader(body))
+		auth.SignRequest(r, "game-1", testSecret, time.Now(), nonce, body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, post("0123456789abcdef", body), "signed request should be accepted")
+	assert.Equal(t, http.StatusUnauthorized, post("0123456789abcdef", body), "replayed nonce should be rejected")
+	assert.Equal(t, http.StatusOK, post("fedcba9876543210", body), "new nonce should be accepted")
+	assert.Equal(t, http.StatusBadRequest, post("00112233445566778899", make([]byte, auth.MaxBodySize+1)),
+		"too large body should be refused")
+
+	large := append([]byte(`{"winner":1,"pad":"`), bytes.Repeat([]byte("x"), auth.MaxBodySize)...)
+	large = append(large, []byte(`"}`)...)
+	r := httptest.NewRequest("POST", "/reportMatch?tournamentId=7&match=1", bytes.NewReader(large))
+	auth.SignRequest(r, "game-1", testSecret, time.Now(), "99887766554433221100", large)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "too large signed match result should be refused")
+	r = httptest.NewRequest("POST", "/reportMatch?tournamentId=7&match=1", bytes.NewReader(large))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "too large unsigned match result should be refused")
+}
+
+func TestPlayerRateLimit(t *testing.T) {
+	router := Handler(nil, Options{TokenVerifier: auth.NewVerifier(testSecret, nil, "", ""),
+		RateLimits: entity.RateLimits{Player: entity.RateLimit{Rate: 0.001, Burst: 1}}})
+	defer func() { limiters = nil }()
+	victim := testToken(t, "7", "")
+	target := "/setLimit?playerId=7&kind=deposit&period=day&amount=1.2.3"
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusForbidden, serve(router, target, testToken(t, "9", "")),
+			"other player should be refused, not limited")
+	}
+	assert.Equal(t, http.StatusBadRequest, serve(router, target, victim), "other players should not use up the limit")
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, target, victim), "player over the limit should be limited")
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func submitKYCDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SubmitKYCDocument(db, playerID, vars["kind"], vars["reference"])
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func reviewKYCHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.ReviewKYC(db, playerID, vars["decision"], r.URL.Query().Get("note"), actor(r))
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func kycHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetKYC(db, playerID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func withdrawHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	points, err := strconv.ParseFloat(vars["points"], 64)
+	if err != nil {
+		http.Error(w, "there was a missing or invalid points parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.Withdraw(db, playerID, points)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
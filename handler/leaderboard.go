@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func submitScoreHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	score, err := strconv.ParseFloat(vars["score"], 64)
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid score  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SubmitScore(db, tournamentID, playerID, score)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetLeaderboard(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func setLimitHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	amount, err := strconv.ParseFloat(vars["amount"], 64)
+	if err != nil {
+		http.Error(w, "there was a missing or invalid amount parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SetPlayerLimit(db, playerID, vars["kind"], vars["period"], amount)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func selfExcludeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	days, err := strconv.Atoi(vars["days"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid days parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SelfExclude(db, playerID, days)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func limitsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid playerId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetPlayerLimits(db, playerID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
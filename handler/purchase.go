@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func reEnterTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.ReEnterTournament(db, userID, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func buyAddOnHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.BuyAddOn(db, userID, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func purchasesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetPurchases(db, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func ticketsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetTickets(db, userID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mishelini/entity"
+	"github.com/mishelini/ratelimit"
+)
+
+// limiter rate limiter of one kind of request key. Limiters keyed on the caller run after the
+// authentication, so no one can use up the requests of another caller.
+type limiter struct {
+	kind          string
+	limiter       *ratelimit.Limiter
+	key           func(r *http.Request) string
+	authenticated bool
+}
+
+// limiters rate limiters checked on every request, in order
+var limiters []limiter
+
+// newLimiters returns the limiters of the enabled limits. Requests are limited by client IP,
+// by API key and by the authenticated player.
+func newLimiters(limits entity.RateLimits) []limiter {
+	list := make([]limiter, 0, 3)
+	for _, l := range []struct {
+		kind          string
+		limit         entity.RateLimit
+		key           func(r *http.Request) string
+		authenticated bool
+	}{
+		{"ip", limits.IP, sourceIP, false},
+		{"api_key", limits.APIKey, apiKeyID, true},
+		{"player", limits.Player, playerID, true},
+	} {
+		if l.limit.Rate > 0 {
+			list = append(list, limiter{kind: l.kind, limiter: ratelimit.New(l.limit.Rate, l.limit.Burst), key: l.key,
+				authenticated: l.authenticated})
+		}
+	}
+	return list
+}
+
+// apiKeyID returns the id of the authenticated API key, empty for other callers.
+func apiKeyID(r *http.Request) string {
+	p, ok := r.Context().Value(principalContextKey).(principal)
+	if !ok || p.KeyID == 0 {
+		return ""
+	}
+	return strconv.Itoa(p.KeyID)
+}
+
+// playerID returns the authenticated player, empty for other callers.
+func playerID(r *http.Request) string {
+	p, ok := r.Context().Value(principalContextKey).(principal)
+	if !ok || p.Role != entity.RolePlayer {
+		return ""
+	}
+	return p.Subject
+}
+
+// limit checks the request against the limiters which run before or after the authentication.
+// It writes 429 Too Many Requests and returns false when the request is over a limit.
+func limit(w http.ResponseWriter, r *http.Request, authenticated bool) bool {
+	now := time.Now()
+	for _, l := range limiters {
+		if l.authenticated != authenticated {
+			continue
+		}
+		key := l.key(r)
+		if key == "" {
+			continue
+		}
+		ok, wait := l.limiter.Allow(key, now)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests by "+l.kind, http.StatusTooManyRequests)
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimitMiddleware rejects requests over the client IP limit with 429 Too Many Requests. It runs
+// before the authentication, so clients sending bad credentials are limited too.
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit(w, r, false) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// callerRateLimitMiddleware rejects requests over the API key and player limits with 429 Too Many
+// Requests. It runs after the authentication and counts the requests of the authenticated caller.
+func callerRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit(w, r, true) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// metricsHandler writes the rate limit counters in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP rate_limit_allowed_total Requests allowed by the rate limiter.")
+	fmt.Fprintln(w, "# TYPE rate_limit_allowed_total counter")
+	for _, l := range limiters {
+		fmt.Fprintf(w, "rate_limit_allowed_total{kind=%q} %d\n", l.kind, l.limiter.Allowed())
+	}
+	fmt.Fprintln(w, "# HELP rate_limit_limited_total Requests rejected by the rate limiter.")
+	fmt.Fprintln(w, "# TYPE rate_limit_limited_total counter")
+	for _, l := range limiters {
+		fmt.Fprintf(w, "rate_limit_limited_total{kind=%q} %d\n", l.kind, l.limiter.Limited())
+	}
+	fmt.Fprintln(w, "# HELP rate_limit_keys Keys tracked by the rate limiter.")
+	fmt.Fprintln(w, "# TYPE rate_limit_keys gauge")
+	for _, l := range limiters {
+		fmt.Fprintf(w, "rate_limit_keys{kind=%q} %d\n", l.kind, l.limiter.Keys())
+	}
+}
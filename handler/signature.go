@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/mishelini/auth"
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+)
+
+// signedRoutes routes posting results, game servers must sign these requests when signing is enabled
+var signedRoutes = map[string]bool{
+	"/finishTournament": true,
+	"/submitScore":      true,
+	"/reportMatch":      true,
+}
+
+// useRequestNonce records the nonce of a signed request, tests may replace it
+var useRequestNonce = controller.UseRequestNonce
+
+// signatureMiddleware verifies HMAC signatures of result requests. Signed requests are always verified,
+// unsigned requests are rejected only for game servers, other callers may still post results unsigned.
+func signatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signatures == nil || !signedRoutes[routePath(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !auth.IsSigned(r) {
+			p, _ := r.Context().Value(principalContextKey).(principal)
+			if p.Role == entity.RoleGameServer {
+				http.Error(w, "request signature required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := auth.ReadBody(w, r)
+		if err != nil {
+			http.Error(w, "there was an unreadable request body..", http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		signed, err := signatures.Verify(r, body)
+		if err != nil {
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			log.Println(err)
+			return
+		}
+		fresh, err := useRequestNonce(db, signed.Integration, signed.Nonce, signed.Expires)
+		if err != nil {
+			http.Error(w, "this is Database Error", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		if !fresh {
+			http.Error(w, "replayed request", http.StatusUnauthorized)
+			log.Printf("replayed request of integration %s nonce %s", signed.Integration, signed.Nonce)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
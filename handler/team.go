@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+)
+
+func createTeamHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	captainID, err := strconv.Atoi(vars["captainId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid captainId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.CreateTeam(db, vars["name"], captainID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func addTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamID, err := strconv.Atoi(vars["teamId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid teamId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	captainID, err := strconv.Atoi(vars["captainId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid captainId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	share, err := optionalFloat(r, "share")
+	if err != nil {
+		http.Error(w, "there was an invalid share parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	if share == 0 {
+		share = 1
+	}
+	js, err := controller.AddTeamMember(db, teamID, captainID, playerID, share)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func acceptTeamInviteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamID, err := strconv.Atoi(vars["teamId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid teamId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	playerID, err := strconv.Atoi(vars["playerId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid playerId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.AcceptTeamInvite(db, teamID, playerID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func teamHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamID, err := strconv.Atoi(vars["teamId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid teamId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetTeam(db, teamID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func joinTeamTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamID, err := strconv.Atoi(vars["teamId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid teamId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	captainID, err := strconv.Atoi(vars["captainId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid captainId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	tournamentID, err := strconv.Atoi(vars["tournamentId"])
+	if err != nil {
+		http.Error(w, "there was a missing or  invalid tournamentId  parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.JoinTeamTournament(db, teamID, captainID, tournamentID)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mishelini/controller"
+	"github.com/mishelini/entity"
+)
+
+func createTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+	js, err := controller.CreateTemplate(db, template)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func updateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["templateId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid templateId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	template, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+	template.ID = id
+	js, err := controller.UpdateTemplate(db, template)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func activateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["templateId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid templateId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	active, err := strconv.ParseBool(vars["active"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid active parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.SetTemplateActive(db, id, active)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["templateId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid templateId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	err = controller.DeleteTemplate(db, id)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	templatesHandler(w, r)
+}
+
+func templateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["templateId"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid templateId parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	js, err := controller.GetTemplate(db, id)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+func templatesHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := controller.GetTemplates(db)
+	if err != nil {
+		http.Error(w, "this is Database Error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Write(js)
+}
+
+// templateParams parse the template and its tournament settings, it writes the error response
+// and returns false when a parameter is invalid.
+func templateParams(w http.ResponseWriter, r *http.Request) (entity.Template, bool) {
+	vars := mux.Vars(r)
+
+	deposit, err := strconv.ParseFloat(vars["deposit"], 64)
+	if err != nil {
+		http.Error(w, "there was a missing or invalid deposit parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return entity.Template{}, false
+	}
+	lead, err := strconv.Atoi(vars["leadMinutes"])
+	if err != nil {
+		http.Error(w, "there was a missing or invalid leadMinutes parameter..", http.StatusBadRequest)
+		log.Println(err)
+		return entity.Template{}, false
+	}
+	template := entity.Template{
+		Name:        vars["name"],
+		Recurrence:  vars["recurrence"],
+		LeadMinutes: lead,
+		Params:      entity.AnnounceParams{Deposit: deposit},
+	}
+	if !announceParams(w, r, &template.Params) {
+		return entity.Template{}, false
+	}
+	return template, true
+}
@@ -8,14 +8,18 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/mishelini/database"
 
 	"github.com/go-yaml/yaml"
 	// Pure Go Postgres driver for database/sql
 	_ "github.com/lib/pq"
+	"github.com/mishelini/auth"
+	"github.com/mishelini/controller"
 	"github.com/mishelini/entity"
 	"github.com/mishelini/handler"
+	"github.com/mishelini/scheduler"
 )
 
 var (
@@ -45,7 +49,47 @@ func main() {
 		log.Printf("initialize DB: %s", err)
 		return
 	}
-	err = http.ListenAndServe(fmt.Sprintf("%s:%s", appParams.APPHost, appParams.APPPort), handler.Handler(db))
+	if flag.NArg() > 0 && flag.Arg(0) == "apikey" {
+		err = runAPIKeyCommand(db, flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	sched := scheduler.New(db, time.Duration(appParams.SchedulerInterval)*time.Second)
+	go sched.Run()
+	defer sched.Stop()
+
+	verifier, err := auth.LoadVerifier(appParams.JWTSecretFile, appParams.JWTPublicKeyFile, appParams.JWTIssuer, appParams.JWTAudience)
+	if err != nil {
+		log.Printf("load JWT keys: %s", err)
+		return
+	}
+	signatures, err := auth.LoadSignatureVerifier(appParams.SigningSecretsFile, time.Duration(appParams.SignatureSkew)*time.Second)
+	if err != nil {
+		log.Printf("load signing secrets: %s", err)
+		return
+	}
+	server := &http.Server{
+		Addr: fmt.Sprintf("%s:%s", appParams.APPHost, appParams.APPPort),
+		Handler: handler.Handler(db, handler.Options{
+			TokenVerifier: verifier,
+			Signatures:    signatures,
+			RateLimits:    appParams.RateLimits,
+			ClientRoles:   appParams.TLSClientRoles,
+		}),
+	}
+	if appParams.TLSCertFile != "" {
+		server.TLSConfig, err = auth.NewTLSConfig(appParams.TLSCertFile, appParams.TLSKeyFile, appParams.TLSClientCAFile, appParams.TLSRequireClientCert)
+		if err != nil {
+			log.Printf("load TLS certificates: %s", err)
+			return
+		}
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
 	if err != nil {
 		log.Printf("initialize DB: %s", err)
 	}
@@ -75,6 +119,13 @@ func initializeDB(appParams entity.Params) (*sql.DB, error) {
 		return nil, err
 	}
 	database.InitData = appParams.InitData
+	if appParams.LimitCoolingOffHours > 0 {
+		controller.LimitCoolingOff = time.Duration(appParams.LimitCoolingOffHours) * time.Hour
+	}
+	if appParams.SuspendedEntryPolicy != "" {
+		controller.SuspendedEntryPolicy = appParams.SuspendedEntryPolicy
+	}
+	controller.KYCDepositThreshold = appParams.KYCDepositThreshold
 	err = database.CreateTablesIfNotExist(dbConn)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,94 @@
+// Package ratelimit limits request rates with token buckets kept in memory.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sweepInterval how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps one token bucket per key. Every bucket holds up to burst tokens and refills
+// rate tokens per second, a request takes one token.
+type Limiter struct {
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	allowed uint64
+	limited uint64
+}
+
+// New returns limiter allowing rate requests per second with bursts of burst requests.
+// Burst less than 1 is raised to 1.
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of the key. When the bucket is empty it returns false
+// and the time until the next token.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		atomic.AddUint64(&l.allowed, 1)
+		return true, 0
+	}
+	atomic.AddUint64(&l.limited, 1)
+	wait := (1 - b.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// sweep drops the buckets which have refilled completely, they are the same as new buckets.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allowed returns the number of allowed requests.
+func (l *Limiter) Allowed() uint64 {
+	return atomic.LoadUint64(&l.allowed)
+}
+
+// Limited returns the number of rejected requests.
+func (l *Limiter) Limited() uint64 {
+	return atomic.LoadUint64(&l.limited)
+}
+
+// Keys returns the number of tracked keys.
+func (l *Limiter) Keys() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, 3)
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a", now)
+		assert.True(t, ok, "burst request %d should be allowed", i)
+	}
+	ok, wait := l.Allow("a", now)
+	assert.False(t, ok, "request over the burst should be limited")
+	assert.Equal(t, 500*time.Millisecond, wait, "wrong retry delay")
+
+	ok, _ = l.Allow("b", now)
+	assert.True(t, ok, "other keys should have their own bucket")
+
+	ok, _ = l.Allow("a", now.Add(500*time.Millisecond))
+	assert.True(t, ok, "refilled token should be allowed")
+	ok, _ = l.Allow("a", now.Add(500*time.Millisecond))
+	assert.False(t, ok, "bucket should be empty again")
+
+	assert.Equal(t, uint64(5), l.Allowed(), "wrong allowed counter")
+	assert.Equal(t, uint64(2), l.Limited(), "wrong limited counter")
+
+	l.Allow("c", now.Add(time.Hour))
+	assert.Equal(t, 1, l.Keys(), "idle buckets should be dropped")
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mishelini/controller"
+	"github.com/mishelini/database"
+)
+
+// lockName name of the leader lock shared by all application instances.
+const lockName = "scheduler"
+
+// DefaultInterval time between scheduler runs when it is not configured.
+const DefaultInterval = 10 * time.Second
+
+// Scheduler moves tournaments through their states on time. Only the instance holding
+// the leader lock in the database acts, so several instances may run side by side.
+// All decisions are made from the tournament rows, so a restarted scheduler simply catches up.
+type Scheduler struct {
+	db       *sql.DB
+	interval time.Duration
+	holder   string
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns scheduler running every interval.
+func New(db *sql.DB, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		interval: interval,
+		holder:   fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run runs the scheduler until Stop is called.
+func (s *Scheduler) Run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.tick(time.Now())
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			err := database.ReleaseLeaderLock(s.db, lockName, s.holder)
+			if err != nil {
+				log.Printf("scheduler: release lock: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler and releases the leader lock.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Scheduler) tick(now time.Time) {
+	// the lock lives for three intervals so a missed tick does not lose leadership
+	leader, err := database.AcquireLeaderLock(s.db, lockName, s.holder, 3*s.interval)
+	if err != nil {
+		log.Printf("scheduler: acquire lock: %s", err)
+		return
+	}
+	if !leader {
+		return
+	}
+	err = s.runTournaments(now)
+	if err != nil {
+		log.Printf("scheduler: %s", err)
+	}
+}
+
+func (s *Scheduler) runTournaments(now time.Time) error {
+	announced, err := controller.RunTemplates(s.db, now)
+	if len(announced) > 0 {
+		log.Printf("scheduler: announced tournaments %v from templates", announced)
+	}
+	if err != nil {
+		log.Printf("scheduler: run templates: %s", err)
+	}
+	err = database.DeleteExpiredRequestNonces(s.db, now)
+	if err != nil {
+		log.Printf("scheduler: delete expired request nonces: %s", err)
+	}
+	opened, err := database.OpenTournamentRegistrations(s.db, now)
+	if err != nil {
+		return fmt.Errorf("open registrations: %s", err)
+	}
+	if opened > 0 {
+		log.Printf("scheduler: opened registration for %d tournaments", opened)
+	}
+	closed, err := database.CloseTournamentRegistrations(s.db, now)
+	if err != nil {
+		return fmt.Errorf("close registrations: %s", err)
+	}
+	if closed > 0 {
+		log.Printf("scheduler: closed registration for %d tournaments", closed)
+	}
+	ids, err := database.SelectTournamentsToStart(s.db, now)
+	if err != nil {
+		return fmt.Errorf("select tournaments to start: %s", err)
+	}
+	for _, id := range ids {
+		js, err := controller.StartTournament(s.db, id)
+		if err != nil {
+			log.Printf("scheduler: start tournament %d: %s", id, err)
+			continue
+		}
+		log.Printf("scheduler: start tournament %d: %s", id, js)
+	}
+	return nil
+}